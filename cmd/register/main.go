@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"os"
 
@@ -12,7 +13,10 @@ import (
 
 var (
 	BotToken = flag.String("token", "", "Bot access token")
+	AppID    = flag.String("app", "", "Application ID (defaults to APP_ID or the bot user ID)")
+	GuildID  = flag.String("guild", "", "Register commands to a single guild instead of globally")
 	Remove   = flag.Bool("remove", false, "Remove commands instead of registering them")
+	DryRun   = flag.Bool("dry-run", false, "Show the difference between local and registered commands without changing anything")
 )
 
 var s *discordgo.Session
@@ -33,48 +37,137 @@ func init() {
 	}
 }
 
-var (
-	commands = []*discordgo.ApplicationCommand{
-		&command.InfoCommand,
-		&command.IGCommand,
-	}
-)
+var commands = command.Definitions()
 
 func main() {
-	s.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
-		log.Info().
-			Str("username", s.State.User.Username).
-			Str("discriminator", s.State.User.Discriminator).
-			Msg("Logged in as")
-	})
-	err := s.Open()
+	appID := resolveAppID()
+	scope := "global"
+	if *GuildID != "" {
+		scope = "guild:" + *GuildID
+	}
+	log.Info().Str("app_id", appID).Str("scope", scope).Msg("Using application")
+
+	registered, err := s.ApplicationCommands(appID, *GuildID)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Cannot open the session")
+		log.Fatal().Err(err).Msg("Cannot fetch registered commands")
+	}
+
+	switch {
+	case *DryRun:
+		diff(registered)
+	case *Remove:
+		remove(appID, registered)
+	default:
+		overwrite(appID)
 	}
+}
 
-	defer s.Close()
+func resolveAppID() string {
+	if *AppID != "" {
+		return *AppID
+	}
+	if id := os.Getenv("APP_ID"); id != "" {
+		return id
+	}
+	user, err := s.User("@me")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot resolve application ID")
+	}
+	return user.ID
+}
 
-	if *Remove {
-		log.Info().Msg("Removing commands...")
-		for _, v := range commands {
-			err := s.ApplicationCommandDelete(s.State.User.ID, "", v.ID)
-			if err != nil {
-				log.Error().Err(err).Str("command", v.Name).Msg("Cannot remove command")
-			} else {
-				log.Info().Str("command", v.Name).Msg("Removed command")
-			}
+func overwrite(appID string) {
+	log.Info().Int("count", len(commands)).Msg("Bulk overwriting commands...")
+	created, err := s.ApplicationCommandBulkOverwrite(appID, *GuildID, commands)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot overwrite commands")
+	}
+	for _, cmd := range created {
+		log.Info().Str("command", cmd.Name).Str("id", cmd.ID).Msg("Registered command")
+	}
+}
+
+func remove(appID string, registered []*discordgo.ApplicationCommand) {
+	log.Info().Msg("Removing commands...")
+	byName := make(map[string]*discordgo.ApplicationCommand, len(registered))
+	for _, cmd := range registered {
+		byName[cmd.Name] = cmd
+	}
+
+	for _, v := range commands {
+		existing, ok := byName[v.Name]
+		if !ok {
+			log.Warn().Str("command", v.Name).Msg("Command is not registered, skipping")
+			continue
 		}
-	} else {
-		log.Info().Msg("Adding commands...")
-		registeredCommands := make([]*discordgo.ApplicationCommand, len(commands))
-		for i, v := range commands {
-			cmd, err := s.ApplicationCommandCreate(s.State.User.ID, "", v)
-			if err != nil {
-				log.Error().Err(err).Str("command", v.Name).Msg("Cannot create command")
-			}
-			registeredCommands[i] = cmd
+		if err := s.ApplicationCommandDelete(appID, *GuildID, existing.ID); err != nil {
+			log.Error().Err(err).Str("command", v.Name).Msg("Cannot remove command")
+		} else {
+			log.Info().Str("command", v.Name).Str("id", existing.ID).Msg("Removed command")
 		}
 	}
+}
+
+// commandShape holds the fields of a command that we define locally, so
+// server-populated fields like ID and version don't show up as changes.
+type commandShape struct {
+	Type             discordgo.ApplicationCommandType        `json:"type"`
+	Description      string                                  `json:"description"`
+	Options          []*discordgo.ApplicationCommandOption   `json:"options"`
+	Contexts         *[]discordgo.InteractionContextType     `json:"contexts"`
+	IntegrationTypes *[]discordgo.ApplicationIntegrationType `json:"integration_types"`
+	NSFW             *bool                                   `json:"nsfw"`
+}
+
+func fingerprint(cmd *discordgo.ApplicationCommand) string {
+	cmdType := cmd.Type
+	if cmdType == 0 {
+		cmdType = discordgo.ChatApplicationCommand
+	}
+	nsfw := cmd.NSFW
+	if nsfw == nil {
+		nsfw = new(bool)
+	}
+	data, _ := json.Marshal(commandShape{
+		Type:             cmdType,
+		Description:      cmd.Description,
+		Options:          cmd.Options,
+		Contexts:         cmd.Contexts,
+		IntegrationTypes: cmd.IntegrationTypes,
+		NSFW:             nsfw,
+	})
+	return string(data)
+}
+
+func diff(registered []*discordgo.ApplicationCommand) {
+	remote := make(map[string]*discordgo.ApplicationCommand, len(registered))
+	for _, cmd := range registered {
+		remote[cmd.Name] = cmd
+	}
+
+	changes := 0
+	for _, local := range commands {
+		existing, ok := remote[local.Name]
+		delete(remote, local.Name)
+		switch {
+		case !ok:
+			changes++
+			log.Info().Str("command", local.Name).Msg("+ would create")
+		case fingerprint(existing) != fingerprint(local):
+			changes++
+			log.Info().
+				Str("command", local.Name).
+				Str("registered", fingerprint(existing)).
+				Str("local", fingerprint(local)).
+				Msg("~ would update")
+		default:
+			log.Info().Str("command", local.Name).Msg("= unchanged")
+		}
+	}
+	for name, existing := range remote {
+		changes++
+		log.Info().Str("command", name).Str("id", existing.ID).Msg("- would delete")
+	}
 
-	log.Info().Msg("Gracefully shutting down.")
+	log.Info().Int("changes", changes).Msg("Dry run complete, nothing was changed")
 }
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader/ig"
+)
+
+// guildSizeLimit mirrors Discord's upload limit for boosted guilds, since
+// message events don't carry an attachment size limit like interactions do.
+func guildSizeLimit(s *discordgo.Session, guildID string) int64 {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return downloader.DefaultSizeLimit
+	}
+
+	switch guild.PremiumTier {
+	case discordgo.PremiumTier3:
+		return 100 * 1024 * 1024
+	case discordgo.PremiumTier2:
+		return 50 * 1024 * 1024
+	default:
+		return downloader.DefaultSizeLimit
+	}
+}
+
+func (b *bot) autoEmbedHandler() func(*discordgo.Session, *discordgo.MessageCreate) {
+	return func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		if m.Author == nil || m.Author.Bot || m.GuildID == "" {
+			return
+		}
+
+		url, ok := ig.FindURL(m.Content)
+		if !ok {
+			return
+		}
+
+		d, ok := downloader.Get(ig.Name)
+		if !ok {
+			log.Error().Msg("Instagram downloader is not registered")
+			return
+		}
+
+		log.Info().Str("guild", m.GuildID).Str("channel", m.ChannelID).Str("url", url).Msg("Auto-embedding Instagram link")
+
+		sizeLimit := guildSizeLimit(s, m.GuildID)
+		err := b.queue.Submit(m.ID, func(ctx context.Context) {
+			b.autoEmbed(ctx, s, m, d, url, sizeLimit)
+		}, nil)
+		if err != nil {
+			log.Warn().Err(err).Str("url", url).Msg("Skipping auto-embed")
+		}
+	}
+}
+
+func (b *bot) autoEmbed(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, d downloader.Downloader, url string, sizeLimit int64) {
+	if err := s.ChannelTyping(m.ChannelID); err != nil {
+		log.Warn().Err(err).Msg("Failed to send typing indicator")
+	}
+
+	media, err := d.FetchMedia(ctx, url, downloader.Options{SizeLimit: sizeLimit})
+	if err != nil {
+		log.Error().Err(err).Str("url", url).Msg("Failed to auto-embed Instagram link")
+		return
+	}
+
+	for idx, chunk := range downloader.Chunk(media, downloader.MaxAttachmentsPerMessage, sizeLimit) {
+		files := make([]*discordgo.File, 0, len(chunk))
+		for _, item := range chunk {
+			files = append(files, &discordgo.File{
+				Name:        item.Name,
+				ContentType: item.ContentType,
+				Reader:      item.Reader,
+			})
+		}
+
+		msg := &discordgo.MessageSend{
+			Files:           files,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		}
+		if idx == 0 {
+			msg.Reference = m.Reference()
+		}
+
+		if _, err := s.ChannelMessageSendComplex(m.ChannelID, msg); err != nil {
+			log.Error().Err(err).Int("chunk", idx).Msg("Failed to send auto-embed reply")
+			return
+		}
+	}
+
+	if b.config.SuppressEmbeds {
+		_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:      m.ID,
+			Channel: m.ChannelID,
+			Flags:   m.Flags | discordgo.MessageFlagsSuppressEmbeds,
+		})
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to suppress original link embed")
+		}
+	}
+}
@@ -2,14 +2,18 @@ package bot
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/queue"
 )
 
 type bot struct {
 	session *discordgo.Session
 	config  *config
+	server  *http.Server
+	queue   *queue.Queue
 }
 
 func New(config *config) *bot {
@@ -20,9 +24,14 @@ func New(config *config) *bot {
 		panic(fmt.Sprintf("Error creating Discord session, %v", err))
 	}
 
+	if config.AutoEmbed {
+		session.Identify.Intents |= discordgo.IntentsGuildMessages | discordgo.IntentsMessageContent
+	}
+
 	log.Info().Msg("Discord bot session created successfully")
 	return &bot{
 		session: session,
 		config:  config,
+		queue:   queue.New(config.Workers, config.QueueSize, config.JobTimeout),
 	}
 }
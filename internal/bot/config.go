@@ -1,20 +1,86 @@
 package bot
 
 import (
+	"time"
+
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader/ig"
+)
+
+const (
+	defaultJobTimeout    = 2 * time.Minute
+	defaultWorkers       = 2
+	defaultQueueSize     = 20
+	defaultShutdownGrace = 20 * time.Second
+)
+
+const (
+	ModeGateway = "gateway"
+	ModeHTTP    = "http"
 )
 
 type config struct {
-	AppID string `env:"APP_ID,required"`
-	Token string `env:"BOT_TOKEN,required"`
+	AppID      string        `env:"APP_ID,required"`
+	Token      string        `env:"BOT_TOKEN,required"`
+	JobTimeout time.Duration `env:"JOB_TIMEOUT" envDefault:"2m"`
+	Workers    int           `env:"WORKERS" envDefault:"2"`
+	QueueSize  int           `env:"QUEUE_SIZE" envDefault:"20"`
+
+	// ShutdownGrace is how long in-flight jobs may keep running after a
+	// shutdown signal before they are aborted.
+	ShutdownGrace time.Duration `env:"SHUTDOWN_GRACE" envDefault:"20s"`
+
+	// AutoEmbed replies to Instagram links posted in guild channels. It needs
+	// the privileged Message Content intent enabled for the application.
+	AutoEmbed      bool `env:"AUTO_EMBED" envDefault:"false"`
+	SuppressEmbeds bool `env:"SUPPRESS_EMBEDS" envDefault:"false"`
+
+	// CacheDir enables the on-disk media cache when set.
+	CacheDir     string        `env:"CACHE_DIR"`
+	CacheMaxSize int64         `env:"CACHE_MAX_SIZE" envDefault:"1073741824"`
+	CacheTTL     time.Duration `env:"CACHE_TTL" envDefault:"24h"`
+
+	// Proxy sources are merged; with none configured the public
+	// proxyscrape list is used. ProxyURLs entries are "url" or "format|url".
+	ProxyList []string `env:"PROXY_LIST" envSeparator:","`
+	ProxyFile string   `env:"PROXY_FILE"`
+	ProxyURLs []string `env:"PROXY_URLS" envSeparator:","`
+
+	// ProxyPolicy is what a download does while the proxy pool is empty:
+	// "fail", "wait", "direct" or "fallback". All but "fail" wait up to
+	// ProxyWait first; "fallback" then uses ProxyFallback.
+	ProxyPolicy   string        `env:"PROXY_POLICY" envDefault:"wait"`
+	ProxyWait     time.Duration `env:"PROXY_WAIT" envDefault:"30s"`
+	ProxyFallback string        `env:"PROXY_FALLBACK"`
+
+	// ProxyCheckShortcode is a public post fetched through each proxy
+	// candidate during validation. Empty keeps the cheaper HEAD check.
+	ProxyCheckShortcode string `env:"PROXY_CHECK_SHORTCODE"`
+
+	// ProxyStateFile keeps the validated proxy pool across restarts.
+	ProxyStateFile string `env:"PROXY_STATE_FILE"`
+
+	// Mode is either "gateway" or "http". In http mode interactions are
+	// received on HTTPAddr and verified with the application's PublicKey.
+	Mode      string `env:"MODE" envDefault:"gateway"`
+	PublicKey string `env:"PUBLIC_KEY"`
+	HTTPAddr  string `env:"HTTP_ADDR" envDefault:":8080"`
 }
 
 func NewConfig(token, appID string) *config {
 	return &config{
-		Token: token,
-		AppID: appID,
+		Token:         token,
+		AppID:         appID,
+		JobTimeout:    defaultJobTimeout,
+		Workers:       defaultWorkers,
+		QueueSize:     defaultQueueSize,
+		ShutdownGrace: defaultShutdownGrace,
+		Mode:          ModeGateway,
+		HTTPAddr:      ":8080",
+		ProxyPolicy:   string(ig.AcquireWait),
+		ProxyWait:     30 * time.Second,
 	}
 }
 
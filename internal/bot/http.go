@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	maxInteractionBodySize = 1 << 20
+	// Discord expects an answer within 3 seconds. Handlers acknowledge through
+	// the REST callback endpoint, so we give them most of that window before
+	// answering the HTTP request with 202.
+	interactionAckWindow = 2500 * time.Millisecond
+)
+
+type interactionsEndpoint struct {
+	session   *discordgo.Session
+	publicKey ed25519.PublicKey
+	route     func(*discordgo.Session, *discordgo.InteractionCreate, []byte)
+}
+
+func parsePublicKey(key string) (ed25519.PublicKey, error) {
+	decoded, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) != ed25519.PublicKeySize {
+		return nil, errors.New("public key must be 32 bytes")
+	}
+	return ed25519.PublicKey(decoded), nil
+}
+
+func (e *interactionsEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxInteractionBodySize)
+	if !discordgo.VerifyInteraction(r, e.publicKey) {
+		log.Warn().Str("remote", r.RemoteAddr).Msg("Rejected interaction with invalid signature")
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return
+	}
+
+	var i discordgo.InteractionCreate
+	if err := json.Unmarshal(raw, &i); err != nil {
+		log.Error().Err(err).Msg("Failed to decode interaction payload")
+		http.Error(w, "invalid interaction payload", http.StatusBadRequest)
+		return
+	}
+
+	if i.Type == discordgo.InteractionPing {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(discordgo.InteractionResponse{Type: discordgo.InteractionResponsePong})
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.route(e.session, &i, raw)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(interactionAckWindow):
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (b *bot) serveHTTP(stop context.CancelFunc, route func(*discordgo.Session, *discordgo.InteractionCreate, []byte)) *http.Server {
+	publicKey, err := parsePublicKey(b.config.PublicKey)
+	if err != nil {
+		log.Panic().Err(err).Msg("Invalid PUBLIC_KEY for HTTP interactions mode")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/interactions", &interactionsEndpoint{
+		session:   b.session,
+		publicKey: publicKey,
+		route:     route,
+	})
+
+	server := &http.Server{
+		Addr:              b.config.HTTPAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Info().Str("addr", b.config.HTTPAddr).Msg("Serving HTTP interactions endpoint")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("HTTP interactions endpoint failed")
+			stop()
+		}
+	}()
+
+	return server
+}
@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader/ig"
+)
+
+func (c *config) ProxySources() []ig.ProxySource {
+	var sources []ig.ProxySource
+
+	var static []string
+	for _, p := range c.ProxyList {
+		if p = strings.TrimSpace(p); p != "" {
+			static = append(static, p)
+		}
+	}
+	if len(static) > 0 {
+		sources = append(sources, ig.NewStaticProxySource(static))
+	}
+	if c.ProxyFile != "" {
+		sources = append(sources, ig.NewFileProxySource(c.ProxyFile))
+	}
+	for _, spec := range c.ProxyURLs {
+		if strings.TrimSpace(spec) != "" {
+			sources = append(sources, ig.ParseProxySourceURL(spec))
+		}
+	}
+	return sources
+}
+
+func (c *config) ProxyConfig() ig.ProxyConfig {
+	return ig.ProxyConfig{
+		Acquire:        c.acquirePolicy(),
+		CheckShortcode: strings.TrimSpace(c.ProxyCheckShortcode),
+		StateFile:      c.ProxyStateFile,
+	}
+}
+
+func (c *config) acquirePolicy() ig.AcquirePolicy {
+	policy := ig.AcquirePolicy{
+		Mode:     ig.AcquireMode(strings.ToLower(strings.TrimSpace(c.ProxyPolicy))),
+		Wait:     c.ProxyWait,
+		Fallback: strings.TrimSpace(c.ProxyFallback),
+	}
+
+	switch policy.Mode {
+	case ig.AcquireFailFast, ig.AcquireWait, ig.AcquireDirect:
+	case ig.AcquireFallback:
+		if policy.Fallback == "" {
+			log.Warn().Msg("PROXY_POLICY is fallback but PROXY_FALLBACK is empty, downloads will fail once the wait elapses")
+		}
+	default:
+		log.Warn().Str("policy", c.ProxyPolicy).Msg("Unknown PROXY_POLICY, using wait")
+		policy.Mode = ig.AcquireWait
+	}
+	return policy
+}
@@ -2,25 +2,40 @@ package bot
 
 import (
 	"context"
-
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/yokeTH/short-form-discord-app/internal/command"
 )
 
 func (b *bot) Start(ctx context.Context, stop context.CancelFunc) {
-	go func() {
-		if err := b.session.Open(); err != nil {
-			log.Error().Err(err).Msg("Cannot open Discord session")
-			stop()
+	// Jobs are not tied to ctx so a shutdown signal can drain them instead of
+	// abandoning them half-way; see shutdown.
+	b.queue.Start(context.Background())
+	deps := command.NewCommandRouterDependency(b.config.AppID, b.queue)
+
+	switch b.config.Mode {
+	case ModeHTTP:
+		if b.config.AutoEmbed {
+			log.Warn().Msg("Auto-embed needs a gateway connection and is disabled in HTTP mode")
 		}
-	}()
+		b.server = b.serveHTTP(stop, command.NewPayloadRouter(deps))
+	default:
+		go func() {
+			if err := b.session.Open(); err != nil {
+				log.Error().Err(err).Msg("Cannot open Discord session")
+				stop()
+			}
+		}()
 
-	deps := command.NewCommandRouterDependency(b.config.AppID)
-	router := command.NewCommandRouter(deps)
+		b.session.AddHandler(command.NewRawInteractionRouter(deps))
 
-	b.session.AddHandler(router)
+		if b.config.AutoEmbed {
+			log.Info().Bool("suppress_embeds", b.config.SuppressEmbeds).Msg("Auto-embed of Instagram links enabled")
+			b.session.AddHandler(b.autoEmbedHandler())
+		}
+	}
 
 	log.Info().Msg("Bot is now running.")
 
@@ -35,7 +50,21 @@ func (b *bot) Start(ctx context.Context, stop context.CancelFunc) {
 	}
 }
 
+const jobAbortTimeout = 10 * time.Second
+
+// shutdown stops taking new interactions, lets in-flight jobs finish within
+// the grace period and only then closes the Discord connection, which jobs
+// still need to send their replies.
 func (b *bot) shutdown() error {
+	if b.server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := b.server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shut down HTTP server: %w", err)
+		}
+	}
+
+	b.queue.Drain(b.config.ShutdownGrace, jobAbortTimeout)
 	if err := b.session.Close(); err != nil {
 		return fmt.Errorf("failed to close Discord session: %w", err)
 	}
@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const metaFile = "meta.json"
+
+type Item struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+type fileMeta struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+type record struct {
+	Key       string     `json:"key"`
+	Files     []fileMeta `json:"files"`
+	Size      int64      `json:"size"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// accessedAt is kept as the mtime of meta.json so LRU order survives
+	// restarts without rewriting metadata on every hit.
+	accessedAt time.Time
+}
+
+// Cache is a disk-backed LRU of media keyed by an arbitrary string. Each
+// entry lives in its own directory holding meta.json and one file per item.
+// A nil *Cache is a valid, disabled cache.
+type Cache struct {
+	dir      string
+	maxBytes int64
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*record
+	size    int64
+}
+
+// Open loads the index from dir, dropping expired or unreadable entries.
+func Open(dir string, maxBytes int64, ttl time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		entries:  make(map[string]*record),
+	}
+
+	dirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache dir: %w", err)
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		rec, err := c.load(d.Name())
+		if err != nil || c.expired(rec) {
+			log.Warn().Err(err).Str("entry", d.Name()).Msg("Dropping cache entry")
+			os.RemoveAll(filepath.Join(dir, d.Name()))
+			continue
+		}
+		c.entries[rec.Key] = rec
+		c.size += rec.Size
+	}
+
+	c.mu.Lock()
+	c.evictLocked()
+	c.mu.Unlock()
+
+	log.Info().Str("dir", dir).Int("entries", len(c.entries)).Int64("size", c.size).Msg("Media cache loaded")
+	return c, nil
+}
+
+func (c *Cache) Get(key string) ([]Item, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rec, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.expired(rec) {
+		c.removeLocked(rec)
+		return nil, false
+	}
+
+	entryDir := c.entryDir(key)
+	items := make([]Item, 0, len(rec.Files))
+	for idx, f := range rec.Files {
+		data, err := os.ReadFile(filepath.Join(entryDir, strconv.Itoa(idx)))
+		if err != nil {
+			log.Warn().Err(err).Str("key", key).Msg("Cache entry is corrupt, removing")
+			c.removeLocked(rec)
+			return nil, false
+		}
+		items = append(items, Item{Name: f.Name, ContentType: f.ContentType, Data: data})
+	}
+
+	now := time.Now()
+	rec.accessedAt = now
+	os.Chtimes(filepath.Join(entryDir, metaFile), now, now)
+
+	log.Info().Str("key", key).Msg("Media cache hit")
+	return items, true
+}
+
+func (c *Cache) Put(key string, items []Item) error {
+	if c == nil {
+		return nil
+	}
+
+	rec := &record{Key: key, CreatedAt: time.Now(), accessedAt: time.Now()}
+	for _, item := range items {
+		rec.Files = append(rec.Files, fileMeta{
+			Name:        item.Name,
+			ContentType: item.ContentType,
+			Size:        int64(len(item.Data)),
+		})
+		rec.Size += int64(len(item.Data))
+	}
+	if rec.Size > c.maxBytes {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.entries[key]; ok {
+		c.removeLocked(old)
+	}
+
+	// Write into a temp dir and rename so a crash never leaves a half entry.
+	tmpDir, err := os.MkdirTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	for idx, item := range items {
+		if err := os.WriteFile(filepath.Join(tmpDir, strconv.Itoa(idx)), item.Data, 0o644); err != nil {
+			os.RemoveAll(tmpDir)
+			return fmt.Errorf("failed to write cache entry: %w", err)
+		}
+	}
+	meta, err := json.Marshal(rec)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, metaFile), meta, 0o644); err != nil {
+		os.RemoveAll(tmpDir)
+		return fmt.Errorf("failed to write cache metadata: %w", err)
+	}
+	if err := os.Rename(tmpDir, c.entryDir(key)); err != nil {
+		os.RemoveAll(tmpDir)
+		return fmt.Errorf("failed to commit cache entry: %w", err)
+	}
+
+	c.entries[key] = rec
+	c.size += rec.Size
+	c.evictLocked()
+
+	log.Info().Str("key", key).Int64("size", rec.Size).Int64("cache_size", c.size).Msg("Media cached")
+	return nil
+}
+
+func (c *Cache) load(name string) (*record, error) {
+	metaPath := filepath.Join(c.dir, name, metaFile)
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	if hashKey(rec.Key) != name {
+		return nil, fmt.Errorf("cache entry key does not match directory")
+	}
+	info, err := os.Stat(metaPath)
+	if err != nil {
+		return nil, err
+	}
+	rec.accessedAt = info.ModTime()
+	return &rec, nil
+}
+
+func (c *Cache) expired(rec *record) bool {
+	return c.ttl > 0 && time.Since(rec.CreatedAt) > c.ttl
+}
+
+// evictLocked removes expired entries, then least recently used ones until
+// the cache is back under its size limit.
+func (c *Cache) evictLocked() {
+	records := make([]*record, 0, len(c.entries))
+	for _, rec := range c.entries {
+		if c.expired(rec) {
+			c.removeLocked(rec)
+			continue
+		}
+		records = append(records, rec)
+	}
+	if c.size <= c.maxBytes {
+		return
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].accessedAt.Before(records[j].accessedAt)
+	})
+	for _, rec := range records {
+		if c.size <= c.maxBytes {
+			break
+		}
+		log.Info().Str("key", rec.Key).Int64("size", rec.Size).Msg("Evicting cache entry")
+		c.removeLocked(rec)
+	}
+}
+
+func (c *Cache) removeLocked(rec *record) {
+	delete(c.entries, rec.Key)
+	c.size -= rec.Size
+	if err := os.RemoveAll(c.entryDir(rec.Key)); err != nil {
+		log.Warn().Err(err).Str("key", rec.Key).Msg("Failed to remove cache entry")
+	}
+}
+
+func (c *Cache) entryDir(key string) string {
+	return filepath.Join(c.dir, hashKey(key))
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func item(name, data string) Item {
+	return Item{Name: name, ContentType: "video/mp4", Data: []byte(data)}
+}
+
+func mustOpen(t *testing.T, dir string, maxBytes int64, ttl time.Duration) *Cache {
+	t.Helper()
+	c, err := Open(dir, maxBytes, ttl)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return c
+}
+
+func mustPut(t *testing.T, c *Cache, key string, items ...Item) {
+	t.Helper()
+	if err := c.Put(key, items); err != nil {
+		t.Fatalf("Put(%q) error = %v", key, err)
+	}
+}
+
+func assertHit(t *testing.T, c *Cache, key string, want ...Item) {
+	t.Helper()
+	got, ok := c.Get(key)
+	if !ok {
+		t.Fatalf("Get(%q) missed", key)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Get(%q) = %d items, want %d", key, len(got), len(want))
+	}
+	for idx := range want {
+		if got[idx].Name != want[idx].Name || got[idx].ContentType != want[idx].ContentType || string(got[idx].Data) != string(want[idx].Data) {
+			t.Errorf("Get(%q)[%d] = %+v, want %+v", key, idx, got[idx], want[idx])
+		}
+	}
+}
+
+func assertMiss(t *testing.T, c *Cache, key string) {
+	t.Helper()
+	if _, ok := c.Get(key); ok {
+		t.Errorf("Get(%q) hit, want miss", key)
+	}
+}
+
+func TestCache(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, dir string)
+	}{
+		{
+			name: "put then get",
+			run: func(t *testing.T, dir string) {
+				c := mustOpen(t, dir, 1<<20, time.Hour)
+				mustPut(t, c, "post:1", item("image_1.jpg", "one"), item("video_2.mp4", "two"))
+				assertHit(t, c, "post:1", item("image_1.jpg", "one"), item("video_2.mp4", "two"))
+				assertMiss(t, c, "post:2")
+			},
+		},
+		{
+			name: "put replaces existing entry",
+			run: func(t *testing.T, dir string) {
+				c := mustOpen(t, dir, 1<<20, time.Hour)
+				mustPut(t, c, "post:1", item("video.mp4", "old"))
+				mustPut(t, c, "post:1", item("video.mp4", "newer"))
+				assertHit(t, c, "post:1", item("video.mp4", "newer"))
+				if c.size != 5 {
+					t.Errorf("size = %d, want 5", c.size)
+				}
+			},
+		},
+		{
+			name: "entry larger than cache is skipped",
+			run: func(t *testing.T, dir string) {
+				c := mustOpen(t, dir, 4, time.Hour)
+				mustPut(t, c, "post:1", item("video.mp4", "too large"))
+				assertMiss(t, c, "post:1")
+			},
+		},
+		{
+			name: "ttl expiry",
+			run: func(t *testing.T, dir string) {
+				c := mustOpen(t, dir, 1<<20, time.Hour)
+				mustPut(t, c, "post:1", item("video.mp4", "data"))
+				c.entries["post:1"].CreatedAt = time.Now().Add(-2 * time.Hour)
+
+				assertMiss(t, c, "post:1")
+				if _, err := os.Stat(c.entryDir("post:1")); !os.IsNotExist(err) {
+					t.Errorf("expired entry dir still exists: %v", err)
+				}
+				if c.size != 0 {
+					t.Errorf("size = %d, want 0", c.size)
+				}
+			},
+		},
+		{
+			name: "expired entries dropped on open",
+			run: func(t *testing.T, dir string) {
+				c := mustOpen(t, dir, 1<<20, 50*time.Millisecond)
+				mustPut(t, c, "post:1", item("video.mp4", "data"))
+				time.Sleep(100 * time.Millisecond)
+
+				reopened := mustOpen(t, dir, 1<<20, 50*time.Millisecond)
+				if len(reopened.entries) != 0 {
+					t.Errorf("entries = %d, want 0", len(reopened.entries))
+				}
+			},
+		},
+		{
+			name: "evicts least recently accessed",
+			run: func(t *testing.T, dir string) {
+				c := mustOpen(t, dir, 10, time.Hour)
+				mustPut(t, c, "a", item("a", "aaaa"))
+				mustPut(t, c, "b", item("b", "bbbb"))
+				// Touch a so b becomes the least recently used.
+				assertHit(t, c, "a", item("a", "aaaa"))
+				mustPut(t, c, "c", item("c", "cccc"))
+
+				assertMiss(t, c, "b")
+				assertHit(t, c, "a", item("a", "aaaa"))
+				assertHit(t, c, "c", item("c", "cccc"))
+				if c.size != 8 {
+					t.Errorf("size = %d, want 8", c.size)
+				}
+			},
+		},
+		{
+			name: "reopen populated directory",
+			run: func(t *testing.T, dir string) {
+				c := mustOpen(t, dir, 1<<20, time.Hour)
+				mustPut(t, c, "post:1", item("video.mp4", "one"))
+				mustPut(t, c, "post:2", item("image.jpg", "two"), item("image_2.jpg", "three"))
+
+				reopened := mustOpen(t, dir, 1<<20, time.Hour)
+				if reopened.size != c.size {
+					t.Errorf("size = %d, want %d", reopened.size, c.size)
+				}
+				assertHit(t, reopened, "post:1", item("video.mp4", "one"))
+				assertHit(t, reopened, "post:2", item("image.jpg", "two"), item("image_2.jpg", "three"))
+			},
+		},
+		{
+			name: "reopen evicts down to a smaller limit by access time",
+			run: func(t *testing.T, dir string) {
+				c := mustOpen(t, dir, 1<<20, time.Hour)
+				mustPut(t, c, "old", item("a", "aaaa"))
+				mustPut(t, c, "new", item("b", "bbbb"))
+				past := time.Now().Add(-time.Hour)
+				if err := os.Chtimes(filepath.Join(c.entryDir("old"), metaFile), past, past); err != nil {
+					t.Fatal(err)
+				}
+
+				reopened := mustOpen(t, dir, 6, time.Hour)
+				assertMiss(t, reopened, "old")
+				assertHit(t, reopened, "new", item("b", "bbbb"))
+			},
+		},
+		{
+			name: "open removes temp and corrupt entries",
+			run: func(t *testing.T, dir string) {
+				c := mustOpen(t, dir, 1<<20, time.Hour)
+				mustPut(t, c, "good", item("video.mp4", "data"))
+
+				junk := []string{
+					filepath.Join(dir, ".tmp-123"),
+					filepath.Join(dir, hashKey("bad-json")),
+					filepath.Join(dir, "not-a-hash"),
+				}
+				for _, d := range junk {
+					if err := os.MkdirAll(d, 0o755); err != nil {
+						t.Fatal(err)
+					}
+				}
+				os.WriteFile(filepath.Join(junk[0], "0"), []byte("partial"), 0o644)
+				os.WriteFile(filepath.Join(junk[1], metaFile), []byte("{not json"), 0o644)
+				// A valid record in a directory that doesn't match its key.
+				meta, err := os.ReadFile(filepath.Join(c.entryDir("good"), metaFile))
+				if err != nil {
+					t.Fatal(err)
+				}
+				os.WriteFile(filepath.Join(junk[2], metaFile), meta, 0o644)
+
+				reopened := mustOpen(t, dir, 1<<20, time.Hour)
+				for _, d := range junk {
+					if _, err := os.Stat(d); !os.IsNotExist(err) {
+						t.Errorf("%s was not removed: %v", filepath.Base(d), err)
+					}
+				}
+				assertHit(t, reopened, "good", item("video.mp4", "data"))
+				if len(reopened.entries) != 1 {
+					t.Errorf("entries = %d, want 1", len(reopened.entries))
+				}
+			},
+		},
+		{
+			name: "missing data file removes entry on get",
+			run: func(t *testing.T, dir string) {
+				c := mustOpen(t, dir, 1<<20, time.Hour)
+				mustPut(t, c, "post:1", item("video.mp4", "data"))
+				if err := os.Remove(filepath.Join(c.entryDir("post:1"), strconv.Itoa(0))); err != nil {
+					t.Fatal(err)
+				}
+
+				assertMiss(t, c, "post:1")
+				if c.size != 0 {
+					t.Errorf("size = %d, want 0", c.size)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, t.TempDir())
+		})
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	if err := c.Put("key", []Item{item("video.mp4", "data")}); err != nil {
+		t.Errorf("Put() error = %v", err)
+	}
+	assertMiss(t, c, "key")
+}
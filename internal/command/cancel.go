@@ -0,0 +1,99 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// cancelCustomIDPrefix is followed by "<job id>:<requester id>".
+const cancelCustomIDPrefix = "cancel:"
+
+func cancelButton(jobID, requesterID string) discordgo.MessageComponent {
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Cancel",
+				Style:    discordgo.DangerButton,
+				CustomID: cancelCustomIDPrefix + jobID + ":" + requesterID,
+			},
+		},
+	}
+}
+
+// sendCancelPrompt posts the Cancel button as an ephemeral follow-up so only
+// the requester sees it. It returns nil if the follow-up could not be sent.
+func sendCancelPrompt(s *discordgo.Session, interaction *discordgo.Interaction, jobID, requesterID string) *discordgo.Message {
+	msg, err := s.FollowupMessageCreate(interaction, true, &discordgo.WebhookParams{
+		Content:    "Changed your mind? You can cancel this download.",
+		Components: []discordgo.MessageComponent{cancelButton(jobID, requesterID)},
+		Flags:      discordgo.MessageFlagsEphemeral,
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("job", jobID).Msg("Failed to send cancel prompt")
+		return nil
+	}
+	return msg
+}
+
+func removeCancelPrompt(s *discordgo.Session, interaction *discordgo.Interaction, prompt *discordgo.Message) {
+	if prompt == nil {
+		return
+	}
+	if err := s.FollowupMessageDelete(interaction, prompt.ID); err != nil {
+		log.Warn().Err(err).Msg("Failed to remove cancel prompt")
+	}
+}
+
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+func CancelHandler(s *discordgo.Session, i *discordgo.InteractionCreate, deps *commandRouterDependency) {
+	log.Info().Msg("CancelHandler invoked")
+
+	jobID, requesterID, ok := strings.Cut(strings.TrimPrefix(i.MessageComponentData().CustomID, cancelCustomIDPrefix), ":")
+	if !ok {
+		log.Warn().Str("custom_id", i.MessageComponentData().CustomID).Msg("Malformed cancel custom_id")
+		return
+	}
+
+	// The prompt is ephemeral, so this only guards against forged clicks.
+	if interactionUserID(i) != requesterID {
+		respondEphemeral(s, i, "Only the person who started this download can cancel it.")
+		return
+	}
+
+	if !deps.queue.Cancel(jobID) {
+		respondEphemeral(s, i, "This download has already finished.")
+		return
+	}
+
+	// The job edits the message itself once it notices the cancellation.
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to acknowledge cancel button")
+	}
+}
+
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send ephemeral response")
+	}
+}
@@ -0,0 +1,63 @@
+package command
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+)
+
+var DLCommand = discordgo.ApplicationCommand{
+	Name:        "dl",
+	Description: "Download media from any supported site by URL",
+	Type:        discordgo.ChatApplicationCommand,
+	Contexts: &[]discordgo.InteractionContextType{
+		discordgo.InteractionContextBotDM,
+		discordgo.InteractionContextGuild,
+		discordgo.InteractionContextPrivateChannel,
+	},
+	IntegrationTypes: &[]discordgo.ApplicationIntegrationType{
+		discordgo.ApplicationIntegrationUserInstall,
+		discordgo.ApplicationIntegrationGuildInstall,
+	},
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "url",
+			Description: "The URL to process",
+			Required:    true,
+		},
+	},
+}
+
+func DLHandler(s *discordgo.Session, i *discordgo.InteractionCreate, deps *commandRouterDependency) {
+	log.Info().Msg("DLHandler invoked")
+	if i.Type != discordgo.InteractionApplicationCommand {
+		log.Warn().Msg("Interaction type is not ApplicationCommand, returning")
+		return
+	}
+
+	url := urlOption(i)
+	if url == "" {
+		log.Warn().Msg("No URL provided in command options, returning")
+		return
+	}
+
+	d, err := downloader.Lookup(url)
+	if err != nil {
+		log.Warn().Str("url", url).Msg("No downloader matched URL")
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Unsupported URL: no downloader can handle this link.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to send unsupported URL response")
+		}
+		return
+	}
+
+	log.Info().Str("downloader", d.Name()).Str("url", url).Msg("Dispatching to downloader")
+	runDownload(s, i, deps, d, url)
+}
@@ -0,0 +1,159 @@
+package command
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+	"github.com/yokeTH/short-form-discord-app/internal/queue"
+)
+
+func urlOption(i *discordgo.InteractionCreate) string {
+	data := i.ApplicationCommandData()
+	for _, option := range data.Options {
+		if option.Name == "url" && option.Type == discordgo.ApplicationCommandOptionString {
+			return option.StringValue()
+		}
+	}
+	return ""
+}
+
+func runDownload(s *discordgo.Session, i *discordgo.InteractionCreate, deps *commandRouterDependency, d downloader.Downloader, url string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to defer interaction response")
+		return
+	}
+
+	sizeLimit := deps.attachmentSizeLimit(i)
+	progress := newProgressRenderer(s, i.Interaction)
+	prompt := sendCancelPrompt(s, i.Interaction, i.ID, interactionUserID(i))
+	err = deps.queue.Submit(i.ID, func(ctx context.Context) {
+		defer removeCancelPrompt(s, i.Interaction, prompt)
+		downloadAndReply(ctx, s, i, d, url, sizeLimit, progress)
+	}, progress.Queued)
+	if err != nil {
+		removeCancelPrompt(s, i.Interaction, prompt)
+		progress.Stop()
+		content := "Failed to queue download: " + err.Error()
+		switch {
+		case errors.Is(err, queue.ErrFull):
+			content = "The bot is busy right now, please try again in a minute."
+		case errors.Is(err, queue.ErrClosed):
+			content = "The bot is restarting, please try again shortly."
+		}
+		editResponse(s, i.Interaction, content)
+	}
+}
+
+func downloadAndReply(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, d downloader.Downloader, url string, sizeLimit int64, progress *progressRenderer) {
+	media, err := d.FetchMedia(ctx, url, downloader.Options{
+		SizeLimit: sizeLimit,
+		Progress:  progress.Update,
+	})
+	progress.Stop()
+	// A cancel that lands after the download finished still skips the upload.
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		log.Error().Err(err).Str("downloader", d.Name()).Str("url", url).Msg("Failed to download media")
+		content := "Failed to download media: " + err.Error()
+		switch {
+		case errors.Is(context.Cause(ctx), queue.ErrCancelled):
+			content = "Download cancelled."
+		case errors.Is(context.Cause(ctx), queue.ErrShutdown):
+			content = "The bot is restarting, please retry in a moment."
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			content = "Download timed out, please try again later."
+		}
+		editResponse(s, i.Interaction, content)
+		return
+	}
+
+	log.Info().Str("downloader", d.Name()).Str("url", url).Int("count", len(media)).Msg("Media downloaded successfully, sending to user")
+	editResponse(s, i.Interaction, renderProgress(downloader.Progress{Stage: downloader.StageUploading, Percent: -1}))
+
+	user := i.Member
+	if user == nil && i.User != nil {
+		user = &discordgo.Member{User: i.User}
+	}
+
+	username := func() string {
+		if user != nil && user.User != nil {
+			if user.Nick != "" {
+				return user.Nick
+			}
+			return user.User.Username
+		}
+		return ""
+	}()
+	avatarURL := func() string {
+		if user != nil && user.User != nil {
+			return user.User.AvatarURL("")
+		}
+		return ""
+	}()
+
+	for idx, chunk := range downloader.Chunk(media, downloader.MaxAttachmentsPerMessage, sizeLimit) {
+		files := make([]*discordgo.File, 0, len(chunk))
+		for _, m := range chunk {
+			files = append(files, &discordgo.File{
+				Name:        m.Name,
+				ContentType: m.ContentType,
+				Reader:      m.Reader,
+			})
+		}
+
+		// The first chunk replaces the progress message, the rest follow it.
+		if idx == 0 {
+			content := ""
+			components := []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label: "Original Post",
+							Style: discordgo.LinkButton,
+							URL:   url,
+						},
+					},
+				},
+			}
+			_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content:    &content,
+				Files:      files,
+				Components: &components,
+			})
+			if err != nil {
+				log.Error().Err(err).Int("files", len(files)).Msg("Failed to send media response")
+			}
+			continue
+		}
+
+		_, err := s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+			Files:     files,
+			Content:   "",
+			Username:  username,
+			AvatarURL: avatarURL,
+		})
+		if err != nil {
+			log.Error().Err(err).Int("chunk", idx).Int("files", len(files)).Msg("Failed to send media follow-up")
+		}
+	}
+}
+
+// editResponse replaces the response text and clears any components.
+func editResponse(s *discordgo.Session, interaction *discordgo.Interaction, content string) {
+	components := []discordgo.MessageComponent{}
+	_, err := s.InteractionResponseEdit(interaction, &discordgo.WebhookEdit{
+		Content:    &content,
+		Components: &components,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to edit interaction response")
+	}
+}
@@ -0,0 +1,62 @@
+package command
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+)
+
+var DownloadMediaCommand = discordgo.ApplicationCommand{
+	Name: "Download media",
+	Type: discordgo.MessageApplicationCommand,
+	Contexts: &[]discordgo.InteractionContextType{
+		discordgo.InteractionContextBotDM,
+		discordgo.InteractionContextGuild,
+		discordgo.InteractionContextPrivateChannel,
+	},
+	IntegrationTypes: &[]discordgo.ApplicationIntegrationType{
+		discordgo.ApplicationIntegrationUserInstall,
+		discordgo.ApplicationIntegrationGuildInstall,
+	},
+}
+
+func DownloadMediaHandler(s *discordgo.Session, i *discordgo.InteractionCreate, deps *commandRouterDependency) {
+	log.Info().Msg("DownloadMediaHandler invoked")
+	if i.Type != discordgo.InteractionApplicationCommand {
+		log.Warn().Msg("Interaction type is not ApplicationCommand, returning")
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	if data.Resolved == nil || data.Resolved.Messages[data.TargetID] == nil {
+		log.Warn().Str("target", data.TargetID).Msg("Target message not resolved, returning")
+		return
+	}
+	message := data.Resolved.Messages[data.TargetID]
+
+	text := message.Content
+	for _, embed := range message.Embeds {
+		if embed.URL != "" {
+			text += " " + embed.URL
+		}
+	}
+
+	url, d, err := downloader.FindURL(text)
+	if err != nil {
+		log.Warn().Str("message", message.ID).Msg("No supported URL found in target message")
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "No supported link found in this message.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to send no URL response")
+		}
+		return
+	}
+
+	log.Info().Str("downloader", d.Name()).Str("url", url).Msg("Downloading media from target message")
+	runDownload(s, i, deps, d, url)
+}
@@ -3,12 +3,13 @@ package command
 import (
 	"github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
 	"github.com/yokeTH/short-form-discord-app/internal/downloader/ig"
 )
 
 var IGCommand = discordgo.ApplicationCommand{
 	Name:        "ig",
-	Description: "Download an Instagram video by URL",
+	Description: "Download an Instagram video or photo by URL",
 	Type:        discordgo.ChatApplicationCommand,
 	Contexts: &[]discordgo.InteractionContextType{
 		discordgo.InteractionContextBotDM,
@@ -36,75 +37,17 @@ func IGHandler(s *discordgo.Session, i *discordgo.InteractionCreate, deps *comma
 		return
 	}
 
-	data := i.ApplicationCommandData()
-	var url string
-	for _, option := range data.Options {
-		if option.Name == "url" && option.Type == discordgo.ApplicationCommandOptionString {
-			url = option.StringValue()
-			break
-		}
-	}
+	url := urlOption(i)
 	if url == "" {
 		log.Warn().Msg("No URL provided in command options, returning")
 		return
 	}
 
-	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
-	})
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to defer interaction response")
-		return
-	}
-
-	videoData, err := ig.DownloadInstragramVideo(url)
-	if err != nil {
-		log.Error().Err(err).Str("url", url).Msg("Failed to download Instagram video")
-		s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
-			Content: "Failed to download Instagram video: " + err.Error(),
-		})
+	d, ok := downloader.Get(ig.Name)
+	if !ok {
+		log.Error().Msg("Instagram downloader is not registered")
 		return
 	}
 
-	log.Info().Str("url", url).Msg("Instagram video downloaded successfully, sending to user")
-	user := i.Member
-	if user == nil && i.User != nil {
-		user = &discordgo.Member{User: i.User}
-	}
-	s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
-		Files: []*discordgo.File{
-			{
-				Name:        "video.mp4",
-				ContentType: "video/mp4",
-				Reader:      videoData,
-			},
-		},
-		Content: "",
-		Username: func() string {
-			if user != nil && user.User != nil {
-				if user.Nick != "" {
-					return user.Nick
-				}
-				return user.User.Username
-			}
-			return ""
-		}(),
-		AvatarURL: func() string {
-			if user != nil && user.User != nil {
-				return user.User.AvatarURL("")
-			}
-			return ""
-		}(),
-		Components: []discordgo.MessageComponent{
-			discordgo.ActionsRow{
-				Components: []discordgo.MessageComponent{
-					discordgo.Button{
-						Label: "Original Post",
-						Style: discordgo.LinkButton,
-						URL:   url,
-					},
-				},
-			},
-		},
-	})
+	runDownload(s, i, deps, d, url)
 }
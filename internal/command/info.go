@@ -35,7 +35,7 @@ func InfoHandler(s *discordgo.Session, i *discordgo.InteractionCreate, deps *com
 		gitHash = "unknown"
 	}
 
-	content := "This is a bot for downloading Instagram videos and more!"
+	content := "This is a bot for downloading Instagram videos, photos and more!"
 
 	embed := &discordgo.MessageEmbed{
 		Title:       "Bot Information",
@@ -47,7 +47,7 @@ func InfoHandler(s *discordgo.Session, i *discordgo.InteractionCreate, deps *com
 			},
 			{
 				Name:  "Usage Example",
-				Value: "`/ig <instagram_url>`",
+				Value: "`/ig <instagram_url>`\n`/dl <url>`",
 			},
 			{
 				Name:  "Git Hash",
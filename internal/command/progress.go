@@ -0,0 +1,105 @@
+package command
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+)
+
+// Interaction webhooks are rate limited, so progress is coalesced and the
+// response is edited at most once per interval.
+const progressEditInterval = 1500 * time.Millisecond
+
+type progressRenderer struct {
+	session     *discordgo.Session
+	interaction *discordgo.Interaction
+
+	mu       sync.Mutex
+	latest   string
+	rendered string
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+func newProgressRenderer(s *discordgo.Session, interaction *discordgo.Interaction) *progressRenderer {
+	r := &progressRenderer{
+		session:     s,
+		interaction: interaction,
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go r.loop()
+	return r
+}
+
+func (r *progressRenderer) Update(p downloader.Progress) {
+	r.set(renderProgress(p))
+}
+
+func (r *progressRenderer) Queued(position int) {
+	r.set(renderProgress(downloader.Progress{
+		Stage:   downloader.StageQueued,
+		Percent: -1,
+		Detail:  fmt.Sprintf("position %d", position),
+	}))
+}
+
+// Stop ends progress edits and waits for any in-flight edit, so a final
+// response written afterwards is never overwritten by a stale update.
+func (r *progressRenderer) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.stopped
+}
+
+func (r *progressRenderer) set(text string) {
+	r.mu.Lock()
+	r.latest = text
+	r.mu.Unlock()
+}
+
+func (r *progressRenderer) loop() {
+	defer close(r.stopped)
+
+	ticker := time.NewTicker(progressEditInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			text := r.latest
+			changed := text != "" && text != r.rendered
+			r.rendered = text
+			r.mu.Unlock()
+
+			if !changed {
+				continue
+			}
+			_, err := r.session.InteractionResponseEdit(r.interaction, &discordgo.WebhookEdit{
+				Content: &text,
+			})
+			if err != nil {
+				log.Warn().Err(err).Msg("Failed to edit progress message")
+			}
+		}
+	}
+}
+
+func renderProgress(p downloader.Progress) string {
+	text := "⏳ " + string(p.Stage)
+	if p.Percent >= 0 {
+		text += fmt.Sprintf(" %.0f%%", p.Percent)
+	}
+	if p.Detail != "" {
+		text += " (" + p.Detail + ")"
+	}
+	return text + "…"
+}
@@ -0,0 +1,60 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Command pairs an application command definition with its handlers. Both the
+// router and cmd/register are driven by the registry below, so every
+// registered command is routed and every routed command is registered.
+type Command struct {
+	Definition   *discordgo.ApplicationCommand
+	Handler      handlerFunc
+	Autocomplete handlerFunc
+}
+
+var registry = []*Command{
+	{Definition: &InfoCommand, Handler: InfoHandler},
+	{Definition: &IGCommand, Handler: IGHandler},
+	{Definition: &DLCommand, Handler: DLHandler},
+	{Definition: &DownloadMediaCommand, Handler: DownloadMediaHandler},
+}
+
+var commandsByName = func() map[string]*Command {
+	byName := make(map[string]*Command, len(registry))
+	for _, cmd := range registry {
+		if cmd.Handler == nil {
+			panic(fmt.Sprintf("command: %q has no handler", cmd.Definition.Name))
+		}
+		if _, ok := byName[cmd.Definition.Name]; ok {
+			panic(fmt.Sprintf("command: %q registered twice", cmd.Definition.Name))
+		}
+		byName[cmd.Definition.Name] = cmd
+	}
+	return byName
+}()
+
+// Definitions returns the application commands to register with Discord.
+func Definitions() []*discordgo.ApplicationCommand {
+	defs := make([]*discordgo.ApplicationCommand, 0, len(registry))
+	for _, cmd := range registry {
+		defs = append(defs, cmd.Definition)
+	}
+	return defs
+}
+
+func commandHandler(name string) handlerFunc {
+	if cmd, ok := commandsByName[name]; ok {
+		return cmd.Handler
+	}
+	return nil
+}
+
+func autocompleteHandler(name string) handlerFunc {
+	if cmd, ok := commandsByName[name]; ok {
+		return cmd.Autocomplete
+	}
+	return nil
+}
@@ -1,33 +1,141 @@
 package command
 
 import (
+	"encoding/json"
+	"runtime/debug"
+	"strings"
+	"sync"
+
 	"github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+	"github.com/yokeTH/short-form-discord-app/internal/queue"
 )
 
 type commandRouterDependency struct {
 	appID string
+	queue *queue.Queue
+
+	// attachmentLimits holds the attachment_size_limit of interactions that
+	// are currently being routed, keyed by interaction ID. discordgo does not
+	// decode this field, so it is read from the raw gateway payload.
+	attachmentLimits sync.Map
 }
 
-func NewCommandRouterDependency(appID string) *commandRouterDependency {
+func NewCommandRouterDependency(appID string, q *queue.Queue) *commandRouterDependency {
 	return &commandRouterDependency{
 		appID: appID,
+		queue: q,
+	}
+}
+
+func (deps *commandRouterDependency) attachmentSizeLimit(i *discordgo.InteractionCreate) int64 {
+	if limit, ok := deps.attachmentLimits.Load(i.ID); ok {
+		return limit.(int64)
+	}
+	return downloader.DefaultSizeLimit
+}
+
+type rawInteraction struct {
+	AttachmentSizeLimit int64 `json:"attachment_size_limit"`
+}
+
+// NewRawInteractionRouter wraps the command router as a raw gateway event
+// handler so fields discordgo drops, like attachment_size_limit, are kept.
+func NewRawInteractionRouter(deps *commandRouterDependency) func(*discordgo.Session, *discordgo.Event) {
+	route := NewPayloadRouter(deps)
+	return func(s *discordgo.Session, e *discordgo.Event) {
+		i, ok := e.Struct.(*discordgo.InteractionCreate)
+		if !ok {
+			return
+		}
+		route(s, i, e.RawData)
 	}
 }
 
+// NewPayloadRouter routes an interaction together with the raw JSON payload
+// it was decoded from. It is shared by the gateway and HTTP endpoint modes.
+func NewPayloadRouter(deps *commandRouterDependency) func(*discordgo.Session, *discordgo.InteractionCreate, []byte) {
+	router := NewCommandRouter(deps)
+	return func(s *discordgo.Session, i *discordgo.InteractionCreate, raw []byte) {
+		var extra rawInteraction
+		if err := json.Unmarshal(raw, &extra); err != nil {
+			log.Warn().Err(err).Msg("Failed to decode raw interaction payload")
+		}
+		if extra.AttachmentSizeLimit > 0 {
+			deps.attachmentLimits.Store(i.ID, extra.AttachmentSizeLimit)
+			defer deps.attachmentLimits.Delete(i.ID)
+		}
+
+		router(s, i)
+	}
+}
+
+type handlerFunc func(*discordgo.Session, *discordgo.InteractionCreate, *commandRouterDependency)
+
+var (
+	// componentHandlers and modalHandlers are keyed by custom_id prefix, so a
+	// custom_id like "cancel:<job>" is routed to the "cancel:" handler.
+	componentHandlers = map[string]handlerFunc{
+		cancelCustomIDPrefix: CancelHandler,
+	}
+	modalHandlers = map[string]handlerFunc{}
+)
+
 func NewCommandRouter(deps *commandRouterDependency) func(*discordgo.Session, *discordgo.InteractionCreate) {
 	return func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		cmdName := i.ApplicationCommandData().Name
-		log.Info().Str("command", cmdName).Msg("Routing command")
-		switch cmdName {
-		case InfoCommand.Name:
-			log.Info().Msg("Routing to InfoHandler")
-			InfoHandler(s, i, deps)
-		case IGCommand.Name:
-			log.Info().Msg("Routing to IGHandler")
-			IGHandler(s, i, deps)
+		switch i.Type {
+		case discordgo.InteractionApplicationCommand:
+			cmdName := i.ApplicationCommandData().Name
+			log.Info().Str("command", cmdName).Msg("Routing command")
+			dispatch(s, i, deps, "command", cmdName, commandHandler(cmdName))
+		case discordgo.InteractionApplicationCommandAutocomplete:
+			cmdName := i.ApplicationCommandData().Name
+			log.Info().Str("command", cmdName).Msg("Routing autocomplete")
+			dispatch(s, i, deps, "autocomplete", cmdName, autocompleteHandler(cmdName))
+		case discordgo.InteractionMessageComponent:
+			customID := i.MessageComponentData().CustomID
+			log.Info().Str("custom_id", customID).Msg("Routing component")
+			dispatch(s, i, deps, "component", customID, matchPrefix(componentHandlers, customID))
+		case discordgo.InteractionModalSubmit:
+			customID := i.ModalSubmitData().CustomID
+			log.Info().Str("custom_id", customID).Msg("Routing modal submit")
+			dispatch(s, i, deps, "modal", customID, matchPrefix(modalHandlers, customID))
 		default:
-			log.Warn().Str("command", cmdName).Msg("Unknown command received")
+			log.Warn().Stringer("type", i.Type).Msg("Unsupported interaction type received")
+		}
+	}
+}
+
+func dispatch(s *discordgo.Session, i *discordgo.InteractionCreate, deps *commandRouterDependency, kind, key string, handler handlerFunc) {
+	if handler == nil {
+		log.Warn().Str("kind", kind).Str("key", key).Msg("No handler registered for interaction")
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().
+				Interface("panic", r).
+				Str("kind", kind).
+				Str("key", key).
+				Bytes("stack", debug.Stack()).
+				Msg("Recovered from panic in interaction handler")
+		}
+	}()
+
+	handler(s, i, deps)
+}
+
+// matchPrefix returns the handler with the longest prefix of customID.
+func matchPrefix(handlers map[string]handlerFunc, customID string) handlerFunc {
+	var best handlerFunc
+	bestLen := -1
+	for prefix, handler := range handlers {
+		if strings.HasPrefix(customID, prefix) && len(prefix) > bestLen {
+			best = handler
+			bestLen = len(prefix)
 		}
 	}
+	return best
 }
@@ -0,0 +1,25 @@
+package downloader
+
+const MaxAttachmentsPerMessage = 10
+
+// Chunk splits media into groups that each fit within Discord's
+// per-message attachment count and total upload size.
+func Chunk(media []*Media, maxFiles int, maxBytes int64) [][]*Media {
+	var chunks [][]*Media
+	var current []*Media
+	var currentSize int64
+
+	for _, m := range media {
+		if len(current) > 0 && (len(current) >= maxFiles || currentSize+m.Size > maxBytes) {
+			chunks = append(chunks, current)
+			current = nil
+			currentSize = 0
+		}
+		current = append(current, m)
+		currentSize += m.Size
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
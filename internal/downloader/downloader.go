@@ -0,0 +1,54 @@
+package downloader
+
+import (
+	"context"
+	"io"
+)
+
+const DefaultSizeLimit = 8 * 1024 * 1024
+
+type Downloader interface {
+	// Name is the provider identifier, e.g. "instagram".
+	Name() string
+	// Match reports whether the provider can handle the given URL.
+	Match(url string) bool
+	FetchMetadata(ctx context.Context, url string) (*Metadata, error)
+	FetchMedia(ctx context.Context, url string, opts Options) ([]*Media, error)
+}
+
+type Options struct {
+	// SizeLimit is the upload budget per attachment in bytes. Zero means
+	// DefaultSizeLimit.
+	SizeLimit int64
+	// Progress, when set, receives pipeline events as the download advances.
+	Progress ProgressFunc
+}
+
+func (o Options) Limit() int64 {
+	if o.SizeLimit <= 0 {
+		return DefaultSizeLimit
+	}
+	return o.SizeLimit
+}
+
+func (o Options) Report(p Progress) {
+	if o.Progress != nil {
+		o.Progress(p)
+	}
+}
+
+type Metadata struct {
+	Provider string
+	ID       string
+	URL      string
+	Author   string
+	IsVideo  bool
+}
+
+type Media struct {
+	Name        string
+	ContentType string
+	Reader      io.Reader
+	// Size in bytes, or 0 when unknown.
+	Size int64
+}
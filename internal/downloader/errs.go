@@ -0,0 +1,7 @@
+package downloader
+
+import "errors"
+
+var (
+	ErrNoDownloader = errors.New("No downloader supports this url")
+)
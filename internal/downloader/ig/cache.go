@@ -0,0 +1,24 @@
+package ig
+
+import (
+	"time"
+
+	"github.com/yokeTH/short-form-discord-app/internal/cache"
+)
+
+// mediaCache stays nil, and therefore disabled, unless InitializeCache is
+// called with a directory.
+var mediaCache *cache.Cache
+
+func InitializeCache(dir string, maxBytes int64, ttl time.Duration) error {
+	if dir == "" {
+		return nil
+	}
+
+	c, err := cache.Open(dir, maxBytes, ttl)
+	if err != nil {
+		return err
+	}
+	mediaCache = c
+	return nil
+}
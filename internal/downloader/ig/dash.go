@@ -0,0 +1,160 @@
+package ig
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+)
+
+type MPD struct {
+	XMLName xml.Name `xml:"MPD"`
+	Period  Period   `xml:"Period"`
+}
+
+type Period struct {
+	AdaptationSets []AdaptationSet `xml:"AdaptationSet"`
+}
+
+type AdaptationSet struct {
+	ContentType     string           `xml:"contentType,attr"`
+	Representations []Representation `xml:"Representation"`
+}
+
+type Representation struct {
+	ID        string `xml:"id,attr"`
+	Bandwidth int    `xml:"bandwidth,attr"`
+	BaseURL   string `xml:"BaseURL"`
+	MimeType  string `xml:"mimeType,attr"`
+}
+
+// processDASHAndMerge picks the highest video quality whose estimated size,
+// together with the audio track, fits the upload limit, and walks down the
+// ladder when the merged file still comes out too large.
+func processDASHAndMerge(ctx context.Context, manifestContent string, duration float64, opts downloader.Options) ([]byte, error) {
+	var mpd MPD
+	if err := xml.Unmarshal([]byte(manifestContent), &mpd); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	var audioRep *Representation
+	var videoReps []Representation
+
+	for _, aset := range mpd.Period.AdaptationSets {
+		switch aset.ContentType {
+		case "audio":
+			if len(aset.Representations) > 0 && audioRep == nil {
+				audioRep = &aset.Representations[0]
+			}
+		case "video":
+			videoReps = append(videoReps, aset.Representations...)
+		}
+	}
+
+	if audioRep == nil || audioRep.BaseURL == "" {
+		return nil, fmt.Errorf("no audio track found in manifest")
+	}
+	if len(videoReps) == 0 {
+		return nil, fmt.Errorf("no video tracks found in manifest")
+	}
+
+	sort.Slice(videoReps, func(i, j int) bool {
+		return videoReps[i].Bandwidth > videoReps[j].Bandwidth
+	})
+
+	sizeLimit := opts.Limit()
+	audioSize := estimateRepresentationSize(ctx, *audioRep, duration)
+	start := len(videoReps) - 1
+	for idx, rep := range videoReps {
+		estimated := estimateRepresentationSize(ctx, rep, duration) + audioSize
+		if estimated <= sizeLimit {
+			log.Info().Int("bandwidth", rep.Bandwidth).Int64("estimated_size", estimated).Msg("Selected video quality")
+			start = idx
+			break
+		}
+	}
+
+	// Each job gets its own directory so concurrent merges never share files.
+	tempDir, err := os.MkdirTemp("", "ig-dash-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			log.Warn().Err(err).Str("dir", tempDir).Msg("Failed to clean up temp dir")
+		}
+	}()
+
+	videoPath := filepath.Join(tempDir, "video.mp4")
+	audioPath := filepath.Join(tempDir, "audio.mp4")
+	outputPath := filepath.Join(tempDir, "merged.mp4")
+
+	if err := downloadFile(ctx, audioRep.BaseURL, audioPath, downloader.StageDownloadingAudio, opts); err != nil {
+		return nil, fmt.Errorf("failed to download audio stream: %w", err)
+	}
+
+	for idx := start; idx < len(videoReps); idx++ {
+		rep := videoReps[idx]
+		if err := downloadFile(ctx, rep.BaseURL, videoPath, downloader.StageDownloadingVideo, opts); err != nil {
+			return nil, fmt.Errorf("failed to download video stream: %w", err)
+		}
+
+		opts.Report(downloader.Progress{Stage: downloader.StageMerging, Percent: -1})
+		resultData, err := mergeStreams(ctx, videoPath, audioPath, outputPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if int64(len(resultData)) <= sizeLimit {
+			log.Info().Int("bandwidth", rep.Bandwidth).Int("size", len(resultData)).Msg("Merged video fits upload limit")
+			return resultData, nil
+		}
+
+		log.Warn().Int("bandwidth", rep.Bandwidth).Int("size", len(resultData)).Msg("Merged video too large, trying lower quality")
+	}
+
+	return nil, fmt.Errorf("no video quality fits within the upload limit")
+}
+
+// estimateRepresentationSize returns the expected byte size of a stream,
+// using bandwidth × duration when the duration is known and a HEAD request
+// otherwise. It returns 0 when neither is available.
+func estimateRepresentationSize(ctx context.Context, rep Representation, duration float64) int64 {
+	if duration > 0 && rep.Bandwidth > 0 {
+		return int64(float64(rep.Bandwidth) * duration / 8)
+	}
+
+	size, err := contentLength(ctx, rep.BaseURL)
+	if err != nil {
+		log.Warn().Err(err).Str("representation", rep.ID).Msg("Unable to estimate representation size")
+		return 0
+	}
+	return size
+}
+
+func mergeStreams(ctx context.Context, videoPath, audioPath, outputPath string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-y",
+		"-i", videoPath,
+		"-i", audioPath,
+		"-c", "copy",
+		"-map", "0:v:0",
+		"-map", "1:a:0",
+		"-movflags", "+faststart",
+		outputPath,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Error().Str("ffmpeg_output", string(output)).Msg("FFmpeg merge failed")
+		return nil, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	return os.ReadFile(outputPath)
+}
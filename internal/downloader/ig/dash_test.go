@@ -0,0 +1,150 @@
+package ig
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+)
+
+const fixtureManifest = `<?xml version="1.0"?>
+<MPD>
+  <Period>
+    <AdaptationSet contentType="video">
+      <Representation id="v" bandwidth="100000" mimeType="video/mp4"><BaseURL>%[1]s/%[2]s</BaseURL></Representation>
+    </AdaptationSet>
+    <AdaptationSet contentType="audio">
+      <Representation id="a" bandwidth="64000" mimeType="audio/mp4"><BaseURL>%[1]s/audio.mp4</BaseURL></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+func requireFFmpeg(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not found on PATH")
+	}
+}
+
+func runFFmpeg(t *testing.T, args ...string) {
+	t.Helper()
+	if output, err := exec.Command("ffmpeg", args...).CombinedOutput(); err != nil {
+		t.Fatalf("ffmpeg %v: %v\n%s", args, err, output)
+	}
+}
+
+// newFixtureServer serves a one second video and audio track plus a file
+// ffmpeg cannot read.
+func newFixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	runFFmpeg(t, "-y", "-f", "lavfi", "-i", "testsrc=size=160x120:rate=10", "-t", "1",
+		"-c:v", "mpeg4", "-an", filepath.Join(dir, "video.mp4"))
+	runFFmpeg(t, "-y", "-f", "lavfi", "-i", "sine=frequency=440:duration=1",
+		"-c:a", "aac", "-vn", filepath.Join(dir, "audio.mp4"))
+	if err := os.WriteFile(filepath.Join(dir, "broken.mp4"), []byte("not a video"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func assertNoDashDirs(t *testing.T, tmp string) {
+	t.Helper()
+	leftover, err := filepath.Glob(filepath.Join(tmp, "ig-dash-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leftover) > 0 {
+		t.Errorf("temp dirs left behind: %v", leftover)
+	}
+}
+
+func TestProcessDASHAndMergeParallel(t *testing.T) {
+	requireFFmpeg(t)
+	srv := newFixtureServer(t)
+
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	manifest := fmt.Sprintf(fixtureManifest, srv.URL, "video.mp4")
+	outDir := t.TempDir()
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			data, err := processDASHAndMerge(context.Background(), manifest, 1, downloader.Options{})
+			if err != nil {
+				errs[n] = err
+				return
+			}
+			errs[n] = os.WriteFile(filepath.Join(outDir, fmt.Sprintf("out_%d.mp4", n)), data, 0o644)
+		}(n)
+	}
+	wg.Wait()
+
+	for n, err := range errs {
+		if err != nil {
+			t.Fatalf("merge %d: %v", n, err)
+		}
+
+		// ffmpeg exits non-zero without an output file but still lists the
+		// input streams.
+		path := filepath.Join(outDir, fmt.Sprintf("out_%d.mp4", n))
+		output, _ := exec.Command("ffmpeg", "-hide_banner", "-i", path).CombinedOutput()
+		if !strings.Contains(string(output), "Video:") || !strings.Contains(string(output), "Audio:") {
+			t.Errorf("merge %d is missing a stream:\n%s", n, output)
+		}
+	}
+
+	assertNoDashDirs(t, tmp)
+}
+
+func TestProcessDASHAndMergeCleansUpOnFailure(t *testing.T) {
+	requireFFmpeg(t)
+	srv := newFixtureServer(t)
+
+	tests := []struct {
+		name  string
+		video string
+	}{
+		{name: "ffmpeg fails", video: "broken.mp4"},
+		{name: "download fails", video: "missing.mp4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+
+			manifest := fmt.Sprintf(fixtureManifest, srv.URL, tt.video)
+			var wg sync.WaitGroup
+			for n := 0; n < 4; n++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := processDASHAndMerge(context.Background(), manifest, 1, downloader.Options{}); err == nil {
+						t.Error("expected merge to fail")
+					}
+				}()
+			}
+			wg.Wait()
+
+			assertNoDashDirs(t, tmp)
+		})
+	}
+}
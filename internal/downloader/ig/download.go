@@ -2,191 +2,293 @@ package ig
 
 import (
 	"bytes"
-	"encoding/xml"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"sort"
 	"strconv"
 
 	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/cache"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
 )
 
 const API_URL = "https://www.instagram.com/graphql/query"
 
-const maxDiscordFileSize = 8 * 1024 * 1024
-
-type MPD struct {
-	XMLName xml.Name `xml:"MPD"`
-	Period  Period   `xml:"Period"`
-}
-
-type Period struct {
-	AdaptationSets []AdaptationSet `xml:"AdaptationSet"`
-}
-
-type AdaptationSet struct {
-	ContentType     string           `xml:"contentType,attr"`
-	Representations []Representation `xml:"Representation"`
-}
-
-type Representation struct {
-	ID        string `xml:"id,attr"`
-	Bandwidth int    `xml:"bandwidth,attr"`
-	BaseURL   string `xml:"BaseURL"`
-	MimeType  string `xml:"mimeType,attr"`
-}
-
-func DownloadInstragramVideo(url string) (io.Reader, error) {
-	log.Info().Str("url", url).Msg("DownloadInstragramVideo called")
+func DownloadInstagramMedia(ctx context.Context, url string, opts downloader.Options) ([]*downloader.Media, error) {
+	log.Info().Str("url", url).Msg("DownloadInstagramMedia called")
 	postID, ok := parseInstagramVideoURL(url)
 	if !ok {
 		log.Error().Str("url", url).Msg("Unsupported Instagram URL format")
 		return nil, ErrUnsupportURL
 	}
 
-	post, err := fetchInstagramPost(postID)
+	key := fmt.Sprintf("%s:%d", postID, opts.Limit())
+	if items, ok := mediaCache.Get(key); ok {
+		return toMedia(items), nil
+	}
+
+	// Concurrent requests for the same post and budget share one download.
+	return postFlights.Do(ctx, key, opts.Progress, func(ctx context.Context, progress downloader.ProgressFunc) ([]cache.Item, error) {
+		shared := opts
+		shared.Progress = progress
+		media, err := downloadPost(ctx, postID, shared)
+		if err != nil {
+			return nil, err
+		}
+
+		items, err := readAll(media)
+		if err != nil {
+			return nil, err
+		}
+		if err := mediaCache.Put(key, items); err != nil {
+			log.Warn().Err(err).Str("key", key).Msg("Failed to cache media")
+		}
+		return items, nil
+	})
+}
+
+func downloadPost(ctx context.Context, postID string, opts downloader.Options) ([]*downloader.Media, error) {
+	opts.Report(downloader.Progress{Stage: downloader.StageFetchingMetadata, Percent: -1})
+	post, err := fetchInstagramPost(ctx, postID)
 	if err != nil {
 		log.Error().Err(err).Str("postID", postID).Msg("Failed to fetch Instagram post")
 		return nil, fmt.Errorf("Failed to fetch post: %v", err)
 	}
 
-	if !post.Data.XDTShortcodeMedia.IsVideo || post.Data.XDTShortcodeMedia.VideoURL == "" {
+	shortcodeMedia := post.Data.XDTShortcodeMedia
+	if len(shortcodeMedia.EdgeSidecarToChildren.Edges) > 0 {
+		return downloadSidecar(ctx, postID, shortcodeMedia.EdgeSidecarToChildren, opts)
+	}
+
+	if !shortcodeMedia.IsVideo {
+		if shortcodeMedia.DisplayURL == "" && len(shortcodeMedia.DisplayResources) == 0 {
+			log.Info().Interface("post_response", post).Msg("Instagram post response")
+			log.Error().Str("postID", postID).Msg("Post has neither video nor image URL")
+			return nil, ErrNoMedia
+		}
+		image, err := downloadBestImage(ctx, shortcodeMedia.DisplayURL, shortcodeMedia.DisplayResources, opts)
+		if err != nil {
+			log.Error().Err(err).Str("postID", postID).Msg("Failed to download Instagram image")
+			return nil, err
+		}
+		image.Name = "image" + imageExtension(image.ContentType)
+		return []*downloader.Media{image}, nil
+	}
+
+	if shortcodeMedia.VideoURL == "" {
 		log.Info().Interface("post_response", post).Msg("Instagram post response")
-		log.Error().Str("postID", postID).Msg("Post is not a video or missing video URL")
+		log.Error().Str("postID", postID).Msg("Post is a video but missing video URL")
 		return nil, ErrNotVideo
 	}
 
-	videoURL := post.Data.XDTShortcodeMedia.VideoURL
-	sizeOK, size, err := checkURLSize(videoURL)
+	video, err := downloadVideo(ctx, shortcodeMedia.VideoURL, shortcodeMedia.DashInfo.VideoDashManifest, shortcodeMedia.VideoDuration, opts)
+	if err != nil {
+		return nil, err
+	}
+	video.Name = "video.mp4"
+	return []*downloader.Media{video}, nil
+}
+
+func downloadSidecar(ctx context.Context, postID string, sidecar EdgeSidecar, opts downloader.Options) ([]*downloader.Media, error) {
+	log.Info().Str("postID", postID).Int("children", len(sidecar.Edges)).Msg("Downloading Instagram carousel")
+
+	var media []*downloader.Media
+	for idx, edge := range sidecar.Edges {
+		node := edge.Node
+		itemOpts := opts
+		itemOpts.Progress = func(p downloader.Progress) {
+			p.Detail = fmt.Sprintf("item %d/%d", idx+1, len(sidecar.Edges))
+			opts.Report(p)
+		}
+		switch {
+		case node.IsVideo && node.VideoURL != "":
+			video, err := downloadVideo(ctx, node.VideoURL, node.DashInfo.VideoDashManifest, node.VideoDuration, itemOpts)
+			if err != nil {
+				log.Error().Err(err).Str("postID", postID).Int("index", idx).Msg("Failed to download carousel video")
+				return nil, err
+			}
+			video.Name = fmt.Sprintf("video_%d.mp4", idx+1)
+			media = append(media, video)
+		case node.DisplayURL != "" || len(node.DisplayResources) > 0:
+			image, err := downloadBestImage(ctx, node.DisplayURL, node.DisplayResources, itemOpts)
+			if err != nil {
+				log.Error().Err(err).Str("postID", postID).Int("index", idx).Msg("Failed to download carousel image")
+				return nil, err
+			}
+			image.Name = fmt.Sprintf("image_%d%s", idx+1, imageExtension(image.ContentType))
+			media = append(media, image)
+		default:
+			log.Warn().Str("postID", postID).Int("index", idx).Str("typename", node.Typename).Msg("Skipping carousel child without media URL")
+		}
+	}
+
+	if len(media) == 0 {
+		return nil, ErrNoMedia
+	}
+	return media, nil
+}
+
+func downloadVideo(ctx context.Context, videoURL, manifest string, duration float64, opts downloader.Options) (*downloader.Media, error) {
+	sizeLimit := opts.Limit()
+	size, err := contentLength(ctx, videoURL)
 	if err != nil {
 		log.Error().Err(err).Str("videoURL", videoURL).Msg("Failed to check video size")
 		return nil, fmt.Errorf("Failed to check video size: %v", err)
 	}
-	if sizeOK {
+	if size <= sizeLimit {
 		log.Info().Str("videoURL", videoURL).Int64("size", size).Msg("Video is within Discord size limit")
-		resp, err := http.Get(videoURL)
+		resp, err := httpGet(ctx, videoURL)
 		if err != nil {
 			log.Error().Err(err).Str("videoURL", videoURL).Msg("Failed to fetch video")
 			return nil, fmt.Errorf("Failed to fetch video: %v", err)
 		}
-		return resp.Body, nil
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			log.Error().Str("videoURL", videoURL).Str("status", resp.Status).Msg("Failed to fetch video")
+			return nil, fmt.Errorf("Failed to fetch video: bad status: %s", resp.Status)
+		}
+
+		// Buffer right away so carousels don't hold idle CDN connections open
+		// while later children download.
+		data, err := io.ReadAll(newProgressReader(resp.Body, size, downloader.StageDownloadingVideo, opts))
+		if err != nil {
+			log.Error().Err(err).Str("videoURL", videoURL).Msg("Failed to read video")
+			return nil, fmt.Errorf("Failed to read video: %v", err)
+		}
+		return &downloader.Media{
+			ContentType: "video/mp4",
+			Reader:      bytes.NewReader(data),
+			Size:        int64(len(data)),
+		}, nil
 	}
 
-	manifest := post.Data.XDTShortcodeMedia.DashInfo.VideoDashManifest
 	if manifest == "" {
-		log.Error().Str("videoURL", videoURL).Int64("size", size).Msg("Video too large and no DASH manifest available")
-		return nil, fmt.Errorf("Video is larger than 8MB and no lower quality available")
+		log.Info().Str("videoURL", videoURL).Int64("size", size).Msg("Video too large and no DASH manifest available, re-encoding")
+		encoded, err := transcodeToFit(ctx, videoURL, duration, opts)
+		if err != nil {
+			log.Error().Err(err).Str("videoURL", videoURL).Msg("Failed to re-encode video")
+			return nil, fmt.Errorf("Failed to re-encode video: %v", err)
+		}
+		return &downloader.Media{
+			ContentType: "video/mp4",
+			Reader:      bytes.NewReader(encoded),
+			Size:        int64(len(encoded)),
+		}, nil
 	}
 
 	log.Info().Msg("Parsing DASH manifest for lower quality video")
-	mergedReader, err := processDASHAndMerge(manifest)
+	merged, err := processDASHAndMerge(ctx, manifest, duration, opts)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to merge DASH streams")
 		return nil, fmt.Errorf("Failed to process video: %v", err)
 	}
 
-	return mergedReader, nil
+	return &downloader.Media{
+		ContentType: "video/mp4",
+		Reader:      bytes.NewReader(merged),
+		Size:        int64(len(merged)),
+	}, nil
 }
 
-func checkURLSize(url string) (bool, int64, error) {
-	resp, err := http.Head(url)
-	if err != nil {
-		return false, 0, err
+// downloadBestImage tries the display resources from the largest down and
+// returns the first one that fits the upload limit, falling back to displayURL.
+func downloadBestImage(ctx context.Context, displayURL string, resources []DisplayResource, opts downloader.Options) (*downloader.Media, error) {
+	sizeLimit := opts.Limit()
+	opts.Report(downloader.Progress{Stage: downloader.StageDownloadingImage, Percent: -1})
+	candidates := make([]DisplayResource, len(resources))
+	copy(candidates, resources)
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].ConfigWidth > candidates[j].ConfigWidth
+	})
+
+	for _, res := range candidates {
+		if res.Src == "" {
+			continue
+		}
+		size, err := contentLength(ctx, res.Src)
+		if err != nil {
+			log.Warn().Err(err).Str("imageURL", res.Src).Msg("Failed to check image size, trying download")
+		} else if size > sizeLimit {
+			log.Info().Int("width", res.ConfigWidth).Int64("size", size).Msg("Image resource too large, trying smaller")
+			continue
+		}
+
+		image, err := downloadImage(ctx, res.Src)
+		if err != nil {
+			log.Warn().Err(err).Str("imageURL", res.Src).Msg("Failed to download image resource")
+			continue
+		}
+		if image.Size > sizeLimit {
+			continue
+		}
+		log.Info().Int("width", res.ConfigWidth).Int("height", res.ConfigHeight).Int64("size", image.Size).Msg("Selected image resolution")
+		return image, nil
 	}
-	defer resp.Body.Close()
-	cl := resp.Header.Get("Content-Length")
-	if cl == "" {
-		return false, 0, fmt.Errorf("Content-Length header missing")
+
+	if displayURL == "" {
+		return nil, fmt.Errorf("No image resolution fits within the upload limit")
 	}
-	size, err := strconv.ParseInt(cl, 10, 64)
+
+	image, err := downloadImage(ctx, displayURL)
 	if err != nil {
-		return false, 0, err
+		return nil, err
+	}
+	if image.Size > sizeLimit {
+		return nil, fmt.Errorf("Image is larger than the upload limit")
 	}
-	return size <= maxDiscordFileSize, size, nil
+	return image, nil
 }
 
-func processDASHAndMerge(manifestContent string) (io.Reader, error) {
-	var mpd MPD
-	if err := xml.Unmarshal([]byte(manifestContent), &mpd); err != nil {
-		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+func downloadImage(ctx context.Context, imageURL string) (*downloader.Media, error) {
+	resp, err := httpGet(ctx, imageURL)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch image: %v", err)
 	}
+	defer resp.Body.Close()
 
-	var audioURL string
-	var videoReps []Representation
-
-	for _, aset := range mpd.Period.AdaptationSets {
-		switch aset.ContentType {
-		case "audio":
-			if len(aset.Representations) > 0 {
-				audioURL = aset.Representations[0].BaseURL
-			}
-		case "video":
-			videoReps = append(videoReps, aset.Representations...)
-		}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to fetch image: bad status: %s", resp.Status)
 	}
 
-	if audioURL == "" {
-		return nil, fmt.Errorf("no audio track found in manifest")
-	}
-	if len(videoReps) == 0 {
-		return nil, fmt.Errorf("no video tracks found in manifest")
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read image: %v", err)
 	}
 
-	sort.Slice(videoReps, func(i, j int) bool {
-		return videoReps[i].Bandwidth < videoReps[j].Bandwidth
-	})
-
-	targetVideoURL := videoReps[0].BaseURL
-	log.Info().Int("bandwidth", videoReps[0].Bandwidth).Msg("Selected video quality")
-
-	tempDir := os.TempDir()
-	videoPath := filepath.Join(tempDir, "temp_video.mp4")
-	audioPath := filepath.Join(tempDir, "temp_audio.mp4")
-	outputPath := filepath.Join(tempDir, "output_merged.mp4")
-
-	defer os.Remove(videoPath)
-	defer os.Remove(audioPath)
-
-	if err := downloadFile(targetVideoURL, videoPath); err != nil {
-		return nil, fmt.Errorf("failed to download video stream: %w", err)
-	}
-	if err := downloadFile(audioURL, audioPath); err != nil {
-		return nil, fmt.Errorf("failed to download audio stream: %w", err)
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "image/jpeg"
 	}
 
-	cmd := exec.Command("ffmpeg",
-		"-y",
-		"-i", videoPath,
-		"-i", audioPath,
-		"-c", "copy",
-		"-map", "0:v:0",
-		"-map", "1:a:0",
-		"-movflags", "+faststart",
-		outputPath,
-	)
+	return &downloader.Media{
+		ContentType: contentType,
+		Reader:      bytes.NewReader(data),
+		Size:        int64(len(data)),
+	}, nil
+}
 
-	output, err := cmd.CombinedOutput()
+func contentLength(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
 	if err != nil {
-		log.Error().Str("ffmpeg_output", string(output)).Msg("FFmpeg merge failed")
-		return nil, fmt.Errorf("ffmpeg failed: %w", err)
+		return 0, err
 	}
-
-	resultData, err := os.ReadFile(outputPath)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-
-	os.Remove(outputPath)
-
-	return bytes.NewReader(resultData), nil
+	defer resp.Body.Close()
+	cl := resp.Header.Get("Content-Length")
+	if cl == "" {
+		return 0, fmt.Errorf("Content-Length header missing")
+	}
+	return strconv.ParseInt(cl, 10, 64)
 }
 
-func downloadFile(url string, filepath string) error {
-	resp, err := http.Get(url)
+func downloadFile(ctx context.Context, url string, filepath string, stage downloader.Stage, opts downloader.Options) error {
+	resp, err := httpGet(ctx, url)
 	if err != nil {
 		return err
 	}
@@ -202,6 +304,14 @@ func downloadFile(url string, filepath string) error {
 	}
 	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	_, err = io.Copy(out, newProgressReader(resp.Body, resp.ContentLength, stage, opts))
 	return err
 }
+
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
@@ -0,0 +1,50 @@
+package ig
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+)
+
+const Name = "instagram"
+
+type instagram struct{}
+
+func New() *instagram {
+	return &instagram{}
+}
+
+func (d *instagram) Name() string {
+	return Name
+}
+
+func (d *instagram) Match(url string) bool {
+	_, ok := parseInstagramVideoURL(url)
+	return ok
+}
+
+func (d *instagram) FetchMetadata(ctx context.Context, url string) (*downloader.Metadata, error) {
+	postID, ok := parseInstagramVideoURL(url)
+	if !ok {
+		return nil, ErrUnsupportURL
+	}
+
+	post, err := fetchInstagramPost(ctx, postID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch post: %v", err)
+	}
+
+	media := post.Data.XDTShortcodeMedia
+	return &downloader.Metadata{
+		Provider: Name,
+		ID:       postID,
+		URL:      url,
+		Author:   media.Owner.Username,
+		IsVideo:  media.IsVideo,
+	}, nil
+}
+
+func (d *instagram) FetchMedia(ctx context.Context, url string, opts downloader.Options) ([]*downloader.Media, error) {
+	return DownloadInstagramMedia(ctx, url, opts)
+}
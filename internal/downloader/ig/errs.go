@@ -5,4 +5,7 @@ import "errors"
 var (
 	ErrUnsupportURL = errors.New("Unsupport url format")
 	ErrNotVideo     = errors.New("Post not a video")
+	ErrNoMedia      = errors.New("Post has no downloadable media")
+	ErrNoProxy      = errors.New("No proxy available")
+	ErrLoginWall    = errors.New("Instagram requires login")
 )
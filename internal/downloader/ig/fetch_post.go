@@ -1,10 +1,13 @@
 package ig
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
@@ -13,6 +16,11 @@ type response struct {
 	Data struct {
 		XDTShortcodeMedia xdtShortcodeMedia `json:"xdt_shortcode_media"`
 	} `json:"data"`
+
+	// Set when Instagram refuses the query, e.g. a login wall or rate limit.
+	RequireLogin bool   `json:"require_login"`
+	Status       string `json:"status"`
+	Message      string `json:"message"`
 }
 
 type xdtShortcodeMedia struct {
@@ -26,6 +34,9 @@ type xdtShortcodeMedia struct {
 	VideoDuration float64 `json:"video_duration"`
 	HasAudio      bool    `json:"has_audio"`
 
+	DisplayURL       string            `json:"display_url"`
+	DisplayResources []DisplayResource `json:"display_resources"`
+
 	Owner Owner `json:"owner"`
 
 	DashInfo DashInfo `json:"dash_info,omitzero"`
@@ -39,6 +50,12 @@ type Owner struct {
 	IsPrivate bool   `json:"is_private"`
 }
 
+type DisplayResource struct {
+	Src          string `json:"src"`
+	ConfigWidth  int    `json:"config_width"`
+	ConfigHeight int    `json:"config_height"`
+}
+
 type DashInfo struct {
 	IsDashEligible    bool   `json:"is_dash_eligible"`
 	VideoDashManifest string `json:"video_dash_manifest"`
@@ -57,24 +74,95 @@ type SidecarNode struct {
 	ID       string `json:"id"`
 	Typename string `json:"__typename"`
 
-	IsVideo  bool   `json:"is_video"`
-	VideoURL string `json:"video_url"`
-	HasAudio bool   `json:"has_audio"`
+	IsVideo       bool    `json:"is_video"`
+	VideoURL      string  `json:"video_url"`
+	VideoDuration float64 `json:"video_duration"`
+	HasAudio      bool    `json:"has_audio"`
+	DisplayURL    string  `json:"display_url"`
+
+	DisplayResources []DisplayResource `json:"display_resources"`
 
 	DashInfo DashInfo `json:"dash_info,omitzero"`
 }
 
-func fetchInstagramPost(shortcode string) (*response, error) {
+func fetchInstagramPost(ctx context.Context, shortcode string) (*response, error) {
 	log.Info().Str("shortcode", shortcode).Msg("Fetching Instagram post")
-	body := buildPostBody(shortcode)
+	req, err := newPostRequest(ctx, shortcode)
+	if err != nil {
+		log.Error().Err(err).Str("shortcode", shortcode).Msg("Failed to create HTTP request for Instagram post")
+		return nil, err
+	}
 
-	req, err := http.NewRequest(
+	proxy, err := GlobalProxyManager.Acquire(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("shortcode", shortcode).Msg("Failed to acquire proxy")
+		return nil, err
+	}
+
+	res, err := doPostRequest(ctx, proxy, req)
+	if err != nil {
+		log.Error().Err(err).Str("shortcode", shortcode).Str("proxy", proxy.Addr).Msg("Failed to fetch Instagram post")
+		return nil, err
+	}
+	log.Info().Str("shortcode", shortcode).Msg("Instagram post fetched and decoded successfully")
+	return res, nil
+}
+
+// doPostRequest runs a post query through proxy and scores the proxy by the
+// outcome.
+func doPostRequest(ctx context.Context, proxy *Proxy, req *http.Request) (*response, error) {
+	start := time.Now()
+
+	// Failures caused by our own context ending say nothing about the proxy.
+	reportFailure := func(err error) {
+		if ctx.Err() == nil {
+			proxy.ReportFailure(err)
+		}
+	}
+
+	resp, err := proxy.Client.Do(req)
+	if err != nil {
+		reportFailure(err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	res, err := decodePostResponse(resp)
+	if err != nil {
+		reportFailure(err)
+		return nil, err
+	}
+
+	switch {
+	case res.blocked():
+		// The caller still gets the empty post, but the proxy is at fault.
+		log.Warn().Str("proxy", proxy.Addr).Str("message", res.Message).Msg("Instagram blocked post query")
+		reportFailure(ErrLoginWall)
+	case res.hasMedia():
+		proxy.ReportSuccess(time.Since(start))
+	default:
+		// Deleted, private and mistyped posts also come back empty; that
+		// says nothing about the proxy, so its score is left alone.
+	}
+	return res, nil
+}
+
+func (r *response) hasMedia() bool {
+	return r.Data.XDTShortcodeMedia.ID != ""
+}
+
+func (r *response) blocked() bool {
+	return r.RequireLogin || r.Status == "fail"
+}
+
+func newPostRequest(ctx context.Context, shortcode string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
-		"https://www.instagram.com/graphql/query",
-		strings.NewReader(body),
+		API_URL,
+		strings.NewReader(buildPostBody(shortcode)),
 	)
 	if err != nil {
-		log.Error().Err(err).Str("shortcode", shortcode).Msg("Failed to create HTTP request for Instagram post")
 		return nil, err
 	}
 
@@ -89,22 +177,25 @@ func fetchInstagramPost(shortcode string) (*response, error) {
 	req.Header.Set("X-ASBD-ID", "359341")
 	req.Header.Set("Referer", "https://www.instagram.com/p/"+shortcode+"/")
 	req.Header.Set("Cookie", os.Getenv("INSTAGRAM_COOKIE"))
+	return req, nil
+}
 
-	client := GlobalProxyManager.GetClient()
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Error().Err(err).Str("shortcode", shortcode).Msg("Failed to perform HTTP request for Instagram post")
-		return nil, err
+func decodePostResponse(resp *http.Response) (*response, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	// Redirects are followed, so a login wall can end in a 200 HTML page.
+	if resp.Request != nil && isLoginPath(resp.Request.URL.Path) {
+		return nil, ErrLoginWall
 	}
-	defer resp.Body.Close()
 
 	var res response
-	err = json.NewDecoder(resp.Body).Decode(&res)
-	if err != nil {
-		log.Error().Err(err).Str("shortcode", shortcode).Msg("Failed to decode Instagram post response")
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
 		return nil, err
 	}
-	log.Info().Str("shortcode", shortcode).Msg("Instagram post fetched and decoded successfully")
 	return &res, nil
 }
+
+func isLoginPath(path string) bool {
+	return strings.HasPrefix(path, "/accounts/login") || strings.HasPrefix(path, "/challenge")
+}
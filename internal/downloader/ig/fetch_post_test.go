@@ -0,0 +1,112 @@
+package ig
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDoPostRequestScoring(t *testing.T) {
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		wantErr       error
+		wantAnyErr    bool
+		wantSuccesses int
+		wantFailures  int
+	}{
+		{
+			name: "post found",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"data":{"xdt_shortcode_media":{"id":"1","shortcode":"abc"}},"status":"ok"}`))
+			},
+			wantSuccesses: 1,
+		},
+		{
+			name: "missing post leaves score alone",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"data":{"xdt_shortcode_media":null},"status":"ok"}`))
+			},
+		},
+		{
+			name: "login wall json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"message":"Please wait a few minutes before you try again.","require_login":true,"status":"fail"}`))
+			},
+			wantFailures: 1,
+		},
+		{
+			name: "status fail",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"data":{"xdt_shortcode_media":null},"status":"fail"}`))
+			},
+			wantFailures: 1,
+		},
+		{
+			name: "login redirect",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/accounts/login/" {
+					w.Write([]byte("<html>log in</html>"))
+					return
+				}
+				http.Redirect(w, r, "/accounts/login/", http.StatusFound)
+			},
+			wantErr:      ErrLoginWall,
+			wantFailures: 1,
+		},
+		{
+			name: "rate limited",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantAnyErr:   true,
+			wantFailures: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			pm := &ProxyManager{health: make(map[string]*proxyHealth), ready: make(chan struct{})}
+			pm.addOne("proxy:1")
+			proxy := &Proxy{Addr: "proxy:1", Client: srv.Client(), pm: pm}
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL+"/graphql/query", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := doPostRequest(context.Background(), proxy, req)
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+			default:
+				if err != nil || res == nil {
+					t.Fatalf("doPostRequest() = %v, %v; want a response", res, err)
+				}
+			}
+
+			h := pm.health["proxy:1"]
+			if h == nil {
+				h = &proxyHealth{}
+			}
+			if h.successes != tt.wantSuccesses || h.failures != tt.wantFailures {
+				t.Errorf("successes/failures = %d/%d, want %d/%d", h.successes, h.failures, tt.wantSuccesses, tt.wantFailures)
+			}
+			quarantined := !h.quarantinedUntil.IsZero()
+			if quarantined != (tt.wantFailures > 0) {
+				t.Errorf("quarantined = %v, want %v", quarantined, tt.wantFailures > 0)
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package ig
 
 import (
-	"bufio"
+	"context"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,82 +13,114 @@ import (
 )
 
 const (
-	ProxyListURL    = "https://api.proxyscrape.com/v4/free-proxy-list/get?request=display_proxies&proxy_format=protocolipport&format=text"
-	CheckTimeout    = 5 * time.Second
-	CheckTarget     = "https://www.instagram.com"
-	RefreshInterval = 15 * time.Minute
+	ProxyListURL     = "https://api.proxyscrape.com/v4/free-proxy-list/get?request=display_proxies&proxy_format=protocolipport&format=text"
+	CheckTimeout     = 5 * time.Second
+	DeepCheckTimeout = 10 * time.Second
+	CheckTarget      = "https://www.instagram.com"
+	RefreshInterval  = 15 * time.Minute
+	WatchInterval    = 30 * time.Second
 )
 
 type ProxyManager struct {
 	proxies []string
 	mu      sync.Mutex
-	cond    *sync.Cond
 	index   int
-}
+	health  map[string]*proxyHealth
 
-var GlobalProxyManager *ProxyManager
+	// ready is closed while the pool has proxies and replaced once it runs
+	// empty, so Acquire can wait on it alongside a context.
+	ready  chan struct{}
+	policy AcquirePolicy
 
-func InitializeProxies() {
-	pm := &ProxyManager{}
-	pm.cond = sync.NewCond(&pm.mu)
-	GlobalProxyManager = pm
+	checkShortcode string
+	stateFile      string
 
-	go pm.lifecycle()
+	sources []ProxySource
 }
 
-func (pm *ProxyManager) GetClient() *http.Client {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
-
-	for len(pm.proxies) == 0 {
-		log.Warn().Msg("No proxies available, waiting for refresh...")
-		pm.cond.Wait()
-	}
+var GlobalProxyManager *ProxyManager
 
-	proxyStr := pm.proxies[pm.index]
-	pm.index = (pm.index + 1) % len(pm.proxies)
+type ProxyConfig struct {
+	Acquire AcquirePolicy
+	// CheckShortcode, when set, makes validation fetch this public post
+	// through each candidate instead of only reaching instagram.com.
+	CheckShortcode string
+	// StateFile, when set, persists the validated pool across restarts.
+	StateFile string
+}
 
-	if !containsProtocol(proxyStr) {
-		proxyStr = "http://" + proxyStr
+// InitializeProxies starts the proxy pool. Without sources it falls back to
+// the public proxyscrape list.
+func InitializeProxies(cfg ProxyConfig, sources ...ProxySource) {
+	if len(sources) == 0 {
+		sources = []ProxySource{NewHTTPProxySource(ProxyListURL, ProxyFormatText)}
+	}
+	if fallback := cfg.Acquire.Fallback; fallback != "" {
+		if _, err := parseProxyURL(fallback); err != nil {
+			log.Error().Err(err).Str("proxy", fallback).Msg("Ignoring invalid fallback proxy")
+			cfg.Acquire.Fallback = ""
+		}
 	}
 
-	proxyURL, _ := url.Parse(proxyStr)
+	pm := &ProxyManager{
+		sources: sources,
+		health:  make(map[string]*proxyHealth),
+		ready:   make(chan struct{}),
+		policy:  cfg.Acquire,
 
-	return &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyURL(proxyURL),
-		},
-		Timeout: 15 * time.Second,
+		checkShortcode: cfg.CheckShortcode,
+		stateFile:      cfg.StateFile,
 	}
+	GlobalProxyManager = pm
+
+	go pm.lifecycle()
 }
 
 func (pm *ProxyManager) lifecycle() {
+	if loaded := pm.loadState(); len(loaded) > 0 {
+		go pm.revalidate(loaded)
+	}
+
 	log.Info().Msg("Starting initial proxy fetch...")
 	pm.fetchAndScan(true)
+	pm.saveState()
 
 	ticker := time.NewTicker(RefreshInterval)
-	for range ticker.C {
-		log.Info().Msg("Running scheduled proxy refresh (15m)...")
-		pm.fetchAndScan(false)
+	watch := time.NewTicker(WatchInterval)
+	snapshot := time.NewTicker(SnapshotInterval)
+	for {
+		select {
+		case <-ticker.C:
+			log.Info().Msg("Running scheduled proxy refresh (15m)...")
+			pm.fetchAndScan(false)
+			pm.saveState()
+		case <-watch.C:
+			if pm.sourcesChanged() {
+				log.Info().Msg("Proxy source changed, refreshing...")
+				pm.fetchAndScan(false)
+				pm.saveState()
+			}
+		case <-snapshot.C:
+			pm.saveState()
+		}
 	}
 }
 
-func (pm *ProxyManager) fetchAndScan(isStartup bool) {
-	resp, err := http.Get(ProxyListURL)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to fetch proxy list")
-		return
+func (pm *ProxyManager) sourcesChanged() bool {
+	for _, source := range pm.sources {
+		if notifier, ok := source.(changeNotifier); ok && notifier.Changed() {
+			return true
+		}
 	}
-	defer resp.Body.Close()
+	return false
+}
 
-	var rawProxies []string
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line != "" {
-			rawProxies = append(rawProxies, line)
-		}
+func (pm *ProxyManager) fetchAndScan(isStartup bool) {
+	rawProxies := collectProxies(pm.sources)
+	if isStartup {
+		rawProxies = pm.withoutPooled(rawProxies)
 	}
+	log.Info().Int("candidates", len(rawProxies)).Msg("Scanning proxy candidates")
 
 	validChan := make(chan string, len(rawProxies))
 
@@ -100,7 +134,7 @@ func (pm *ProxyManager) fetchAndScan(isStartup bool) {
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			if checkProxy(proxyAddr) {
+			if pm.checkProxy(proxyAddr) {
 				validChan <- proxyAddr
 			}
 		}(p)
@@ -132,8 +166,12 @@ func (pm *ProxyManager) fetchAndScan(isStartup bool) {
 func (pm *ProxyManager) addOne(p string) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
+	// The pool may already hold p when it was restored from a snapshot.
+	if slices.Contains(pm.proxies, p) {
+		return
+	}
 	pm.proxies = append(pm.proxies, p)
-	pm.cond.Signal()
+	pm.signalReadyLocked()
 }
 
 func (pm *ProxyManager) replaceAll(newList []string) {
@@ -144,9 +182,25 @@ func (pm *ProxyManager) replaceAll(newList []string) {
 	pm.proxies = newList
 	pm.index = 0
 
+	// Keep scores for proxies that survived the refresh.
+	kept := make(map[string]*proxyHealth, len(newList))
+	for _, p := range newList {
+		if h, ok := pm.health[p]; ok {
+			kept[p] = h
+		}
+	}
+	pm.health = kept
+
 	log.Info().Int("old_count", prevCount).Int("new_count", len(newList)).Msg("Proxy list refreshed")
 
-	pm.cond.Broadcast()
+	pm.signalReadyLocked()
+}
+
+func (pm *ProxyManager) checkProxy(proxyAddr string) bool {
+	if pm.checkShortcode != "" {
+		return deepCheckProxy(proxyAddr, pm.checkShortcode)
+	}
+	return checkProxy(proxyAddr)
 }
 
 func checkProxy(proxyAddr string) bool {
@@ -169,6 +223,40 @@ func checkProxy(proxyAddr string) bool {
 	return resp.StatusCode < 500
 }
 
+// deepCheckProxy admits a proxy only if Instagram answers a real post query
+// through it. Rate-limited or login-walled proxies pass the HEAD check but
+// fail here.
+func deepCheckProxy(proxyAddr, shortcode string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), DeepCheckTimeout)
+	defer cancel()
+
+	req, err := newPostRequest(ctx, shortcode)
+	if err != nil {
+		return false
+	}
+	proxy, err := newProxy(proxyAddr, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := proxy.Client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	res, err := decodePostResponse(resp)
+	if err != nil {
+		log.Debug().Err(err).Str("proxy", proxyAddr).Msg("Proxy failed deep check")
+		return false
+	}
+	return !res.blocked() && res.hasMedia()
+}
+
 func containsProtocol(addr string) bool {
-	return len(addr) > 7 && (addr[:7] == "http://" || addr[:8] == "https://" || addr[:9] == "socks4://" || addr[:9] == "socks5://")
+	for _, scheme := range []string{"http://", "https://", "socks4://", "socks5://"} {
+		if strings.HasPrefix(addr, scheme) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,144 @@
+package ig
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// AcquireMode decides what Acquire does when the proxy pool is empty.
+type AcquireMode string
+
+const (
+	// AcquireFailFast returns ErrNoProxy immediately.
+	AcquireFailFast AcquireMode = "fail"
+	// AcquireWait waits up to AcquirePolicy.Wait (or the context deadline
+	// when Wait is zero) for a proxy to become available.
+	AcquireWait AcquireMode = "wait"
+	// AcquireDirect waits up to AcquirePolicy.Wait, then connects directly.
+	AcquireDirect AcquireMode = "direct"
+	// AcquireFallback waits up to AcquirePolicy.Wait, then uses
+	// AcquirePolicy.Fallback.
+	AcquireFallback AcquireMode = "fallback"
+)
+
+type AcquirePolicy struct {
+	Mode     AcquireMode
+	Wait     time.Duration
+	Fallback string
+}
+
+// Acquire leases a proxy from the pool. When the pool is empty it follows the
+// manager's AcquirePolicy and never waits past ctx.
+func (pm *ProxyManager) Acquire(ctx context.Context) (*Proxy, error) {
+	var deadline <-chan time.Time
+	if pm.policy.Mode != AcquireFailFast && pm.policy.Wait > 0 {
+		timer := time.NewTimer(pm.policy.Wait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		pm.mu.Lock()
+		if len(pm.proxies) > 0 {
+			addr := pm.pickLocked()
+			proxy, err := newProxy(addr, pm)
+			if err != nil {
+				log.Warn().Err(err).Str("proxy", addr).Msg("Dropping unparsable proxy")
+				pm.removeLocked(addr)
+				pm.mu.Unlock()
+				continue
+			}
+			pm.mu.Unlock()
+			return proxy, nil
+		}
+		ready := pm.ready
+		pm.mu.Unlock()
+
+		if pm.policy.Mode == AcquireFailFast || (pm.policy.Mode != AcquireWait && deadline == nil) {
+			return pm.acquireFallback()
+		}
+
+		log.Warn().Str("policy", string(pm.policy.Mode)).Msg("No proxies available, waiting for refresh...")
+		select {
+		case <-ready:
+		case <-deadline:
+			return pm.acquireFallback()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (pm *ProxyManager) acquireFallback() (*Proxy, error) {
+	switch pm.policy.Mode {
+	case AcquireDirect:
+		log.Warn().Msg("No proxies available, connecting directly")
+		return &Proxy{Client: &http.Client{Timeout: requestTimeout}}, nil
+	case AcquireFallback:
+		if pm.policy.Fallback != "" {
+			// The fallback proxy is not part of the pool, so it is not scored.
+			proxy, err := newProxy(pm.policy.Fallback, nil)
+			if err != nil {
+				log.Error().Err(err).Str("proxy", pm.policy.Fallback).Msg("Fallback proxy is invalid")
+				return nil, ErrNoProxy
+			}
+			log.Warn().Str("proxy", pm.policy.Fallback).Msg("No proxies available, using fallback proxy")
+			return proxy, nil
+		}
+	}
+	return nil, ErrNoProxy
+}
+
+// parseProxyURL parses a proxy address, defaulting to http. A proxy URL
+// without a host is rejected because http.ProxyURL would silently connect
+// directly.
+func parseProxyURL(addr string) (*url.URL, error) {
+	proxyStr := addr
+	if !containsProtocol(proxyStr) {
+		proxyStr = "http://" + proxyStr
+	}
+
+	proxyURL, err := url.Parse(proxyStr)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy %q has no host", addr)
+	}
+	return proxyURL, nil
+}
+
+func newProxy(addr string, pm *ProxyManager) (*Proxy, error) {
+	proxyURL, err := parseProxyURL(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Proxy{
+		Addr: addr,
+		Client: &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyURL(proxyURL),
+			},
+			Timeout: requestTimeout,
+		},
+		pm: pm,
+	}, nil
+}
+
+// signalReadyLocked wakes Acquire callers once the pool has proxies again.
+func (pm *ProxyManager) signalReadyLocked() {
+	if len(pm.proxies) == 0 {
+		return
+	}
+	select {
+	case <-pm.ready:
+	default:
+		close(pm.ready)
+	}
+}
@@ -0,0 +1,40 @@
+package ig
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAcquireFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback string
+		wantErr  error
+	}{
+		{name: "valid", fallback: "tor:9050"},
+		{name: "valid with scheme", fallback: "socks5://tor:9050"},
+		{name: "empty", fallback: "", wantErr: ErrNoProxy},
+		{name: "bad escape", fallback: "http://%zz:80", wantErr: ErrNoProxy},
+		{name: "no host", fallback: "http://", wantErr: ErrNoProxy},
+		{name: "space in host", fallback: "bad host:80", wantErr: ErrNoProxy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := &ProxyManager{
+				health: make(map[string]*proxyHealth),
+				ready:  make(chan struct{}),
+				policy: AcquirePolicy{Mode: AcquireFallback, Fallback: tt.fallback},
+			}
+
+			proxy, err := pm.Acquire(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Acquire() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && proxy.Addr != tt.fallback {
+				t.Errorf("Acquire() proxy = %q, want %q", proxy.Addr, tt.fallback)
+			}
+		})
+	}
+}
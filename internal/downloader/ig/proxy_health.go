@@ -0,0 +1,159 @@
+package ig
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	quarantineBase = 30 * time.Second
+	quarantineMax  = 30 * time.Minute
+	// A proxy that keeps failing after this many quarantines is dropped
+	// from the pool until a refresh brings it back.
+	evictAfterFailures = 6
+	// Acquire rotates between the few best proxies instead of always picking
+	// the single fastest one.
+	preferredPoolSize = 3
+	latencyWeight     = 0.3
+	requestTimeout    = 15 * time.Second
+)
+
+type proxyHealth struct {
+	successes           int
+	failures            int
+	consecutiveFailures int
+	latency             time.Duration
+	quarantinedUntil    time.Time
+}
+
+// score is lower for better proxies: the smoothed latency inflated by the
+// failure rate. Proxies without samples are assumed to be average.
+func (h *proxyHealth) score() float64 {
+	latency := h.latency
+	if latency == 0 {
+		latency = CheckTimeout / 2
+	}
+	total := h.successes + h.failures
+	failureRate := 0.0
+	if total > 0 {
+		failureRate = float64(h.failures) / float64(total)
+	}
+	return float64(latency) * (1 + 4*failureRate)
+}
+
+// Proxy is a proxy leased from the pool. Callers report how the request went
+// so the pool can score, quarantine and evict proxies.
+type Proxy struct {
+	Addr   string
+	Client *http.Client
+	pm     *ProxyManager
+}
+
+func (p *Proxy) ReportSuccess(latency time.Duration) {
+	if p.pm == nil {
+		return
+	}
+	p.pm.reportSuccess(p.Addr, latency)
+}
+
+func (p *Proxy) ReportFailure(err error) {
+	if p.pm == nil {
+		return
+	}
+	p.pm.reportFailure(p.Addr, err)
+}
+
+func (pm *ProxyManager) healthLocked(addr string) *proxyHealth {
+	h, ok := pm.health[addr]
+	if !ok {
+		h = &proxyHealth{}
+		pm.health[addr] = h
+	}
+	return h
+}
+
+func (pm *ProxyManager) reportSuccess(addr string, latency time.Duration) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	h := pm.healthLocked(addr)
+	h.successes++
+	h.consecutiveFailures = 0
+	h.quarantinedUntil = time.Time{}
+	if h.latency == 0 {
+		h.latency = latency
+	} else {
+		h.latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(h.latency))
+	}
+}
+
+func (pm *ProxyManager) reportFailure(addr string, err error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	h := pm.healthLocked(addr)
+	h.failures++
+	h.consecutiveFailures++
+
+	if h.consecutiveFailures >= evictAfterFailures {
+		log.Warn().Err(err).Str("proxy", addr).Int("failures", h.consecutiveFailures).Msg("Evicting failing proxy")
+		pm.removeLocked(addr)
+		return
+	}
+
+	backoff := quarantineBase << (h.consecutiveFailures - 1)
+	if backoff > quarantineMax {
+		backoff = quarantineMax
+	}
+	h.quarantinedUntil = time.Now().Add(backoff)
+	log.Warn().Err(err).Str("proxy", addr).Dur("backoff", backoff).Msg("Quarantining proxy")
+}
+
+func (pm *ProxyManager) removeLocked(addr string) {
+	for idx, p := range pm.proxies {
+		if p == addr {
+			pm.proxies = append(pm.proxies[:idx], pm.proxies[idx+1:]...)
+			if len(pm.proxies) == 0 {
+				pm.ready = make(chan struct{})
+			}
+			break
+		}
+	}
+	delete(pm.health, addr)
+}
+
+// pickLocked returns the next proxy to use: one of the best-scoring healthy
+// proxies, or, when every proxy is quarantined, the one released soonest.
+func (pm *ProxyManager) pickLocked() string {
+	now := time.Now()
+	var healthy []string
+	for _, p := range pm.proxies {
+		if pm.healthLocked(p).quarantinedUntil.Before(now) {
+			healthy = append(healthy, p)
+		}
+	}
+
+	if len(healthy) == 0 {
+		soonest := pm.proxies[0]
+		for _, p := range pm.proxies[1:] {
+			if pm.health[p].quarantinedUntil.Before(pm.health[soonest].quarantinedUntil) {
+				soonest = p
+			}
+		}
+		log.Warn().Str("proxy", soonest).Msg("All proxies quarantined, using the one released soonest")
+		return soonest
+	}
+
+	sort.SliceStable(healthy, func(i, j int) bool {
+		return pm.health[healthy[i]].score() < pm.health[healthy[j]].score()
+	})
+	if len(healthy) > preferredPoolSize {
+		healthy = healthy[:preferredPoolSize]
+	}
+
+	pm.index = (pm.index + 1) % len(healthy)
+	return healthy[pm.index]
+}
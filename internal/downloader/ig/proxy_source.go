@@ -0,0 +1,246 @@
+package ig
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ProxyFormatText = "text"
+	ProxyFormatJSON = "json"
+
+	sourceFetchTimeout = 30 * time.Second
+)
+
+// ProxySource supplies candidate proxy addresses. Addresses may omit the
+// scheme, in which case http:// is assumed.
+type ProxySource interface {
+	Name() string
+	Fetch(ctx context.Context) ([]string, error)
+}
+
+// changeNotifier is implemented by sources that can tell when their content
+// changed, so the pool is rebuilt without waiting for the next refresh.
+type changeNotifier interface {
+	Changed() bool
+}
+
+type staticProxySource struct {
+	proxies []string
+}
+
+func NewStaticProxySource(proxies []string) ProxySource {
+	return &staticProxySource{proxies: proxies}
+}
+
+func (s *staticProxySource) Name() string {
+	return "static"
+}
+
+func (s *staticProxySource) Fetch(ctx context.Context) ([]string, error) {
+	return s.proxies, nil
+}
+
+type fileProxySource struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// NewFileProxySource reads one proxy per line from path; blank lines and
+// lines starting with # are ignored. The file is re-read when it changes.
+func NewFileProxySource(path string) ProxySource {
+	return &fileProxySource{path: path}
+}
+
+func (s *fileProxySource) Name() string {
+	return "file:" + s.path
+}
+
+func (s *fileProxySource) Fetch(ctx context.Context) ([]string, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil {
+		s.mu.Lock()
+		s.modTime = info.ModTime()
+		s.mu.Unlock()
+	}
+
+	return parseProxyText(f)
+}
+
+func (s *fileProxySource) Changed() bool {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !info.ModTime().Equal(s.modTime)
+}
+
+type httpProxySource struct {
+	url    string
+	format string
+}
+
+// NewHTTPProxySource fetches a proxy list from url. The text format is one
+// proxy per line; the json format is an array of strings or of objects with
+// ip/host, port and protocol fields.
+func NewHTTPProxySource(url, format string) ProxySource {
+	if format == "" {
+		format = ProxyFormatText
+	}
+	return &httpProxySource{url: url, format: format}
+}
+
+// ParseProxySourceURL builds an HTTP source from "url" or "format|url".
+func ParseProxySourceURL(spec string) ProxySource {
+	if format, url, ok := strings.Cut(spec, "|"); ok {
+		return NewHTTPProxySource(strings.TrimSpace(url), strings.TrimSpace(format))
+	}
+	return NewHTTPProxySource(strings.TrimSpace(spec), ProxyFormatText)
+}
+
+func (s *httpProxySource) Name() string {
+	return "http:" + s.url
+}
+
+func (s *httpProxySource) Fetch(ctx context.Context) ([]string, error) {
+	resp, err := httpGet(ctx, s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	switch s.format {
+	case ProxyFormatJSON:
+		return parseProxyJSON(resp.Body)
+	case ProxyFormatText:
+		return parseProxyText(resp.Body)
+	default:
+		return nil, fmt.Errorf("unknown proxy list format %q", s.format)
+	}
+}
+
+func parseProxyText(r io.Reader) ([]string, error) {
+	var proxies []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		proxies = append(proxies, line)
+	}
+	return proxies, scanner.Err()
+}
+
+type jsonProxy struct {
+	IP       string          `json:"ip"`
+	Host     string          `json:"host"`
+	Port     json.RawMessage `json:"port"`
+	Protocol string          `json:"protocol"`
+}
+
+func parseProxyJSON(r io.Reader) ([]string, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var proxies []string
+	for _, item := range raw {
+		var addr string
+		if err := json.Unmarshal(item, &addr); err == nil {
+			proxies = append(proxies, addr)
+			continue
+		}
+
+		var p jsonProxy
+		if err := json.Unmarshal(item, &p); err != nil {
+			continue
+		}
+		host := p.IP
+		if host == "" {
+			host = p.Host
+		}
+		port := strings.Trim(string(p.Port), `"`)
+		if _, err := strconv.Atoi(port); host == "" || err != nil {
+			continue
+		}
+
+		addr = host + ":" + port
+		if p.Protocol != "" {
+			addr = strings.ToLower(p.Protocol) + "://" + addr
+		}
+		proxies = append(proxies, addr)
+	}
+	return proxies, nil
+}
+
+// collectProxies fetches every source and returns the merged, de-duplicated
+// list with schemes filled in.
+func collectProxies(sources []ProxySource) []string {
+	seen := make(map[string]struct{})
+	var merged []string
+
+	for _, source := range sources {
+		ctx, cancel := context.WithTimeout(context.Background(), sourceFetchTimeout)
+		proxies, err := source.Fetch(ctx)
+		cancel()
+		if err != nil {
+			log.Error().Err(err).Str("source", source.Name()).Msg("Failed to fetch proxy list")
+			continue
+		}
+
+		added := 0
+		for _, p := range proxies {
+			p = normalizeProxy(p)
+			if p == "" {
+				continue
+			}
+			if _, ok := seen[p]; ok {
+				continue
+			}
+			seen[p] = struct{}{}
+			merged = append(merged, p)
+			added++
+		}
+		log.Info().Str("source", source.Name()).Int("fetched", len(proxies)).Int("added", added).Msg("Proxy source fetched")
+	}
+
+	return merged
+}
+
+func normalizeProxy(p string) string {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return ""
+	}
+	if !containsProtocol(p) {
+		p = "http://" + p
+	}
+	return strings.TrimSuffix(p, "/")
+}
@@ -0,0 +1,41 @@
+package ig
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeProxy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: ""},
+		{in: "   ", want: ""},
+		{in: "a:1", want: "http://a:1"},
+		{in: "tor:9050", want: "http://tor:9050"},
+		{in: "host:80", want: "http://host:80"},
+		{in: "1.2.3.4:8080", want: "http://1.2.3.4:8080"},
+		{in: " 1.2.3.4:8080/ ", want: "http://1.2.3.4:8080"},
+		{in: "http://1.2.3.4:8080", want: "http://1.2.3.4:8080"},
+		{in: "https://proxy:443", want: "https://proxy:443"},
+		{in: "socks4://tor:9050", want: "socks4://tor:9050"},
+		{in: "socks5://tor:9050", want: "socks5://tor:9050"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeProxy(tt.in); got != tt.want {
+			t.Errorf("normalizeProxy(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCollectProxiesStatic(t *testing.T) {
+	source := NewStaticProxySource([]string{"tor:9050", "a:1", "", "http://a:1", "socks5://tor:9050"})
+
+	got := collectProxies([]ProxySource{source})
+	want := []string{"http://tor:9050", "http://a:1", "socks5://tor:9050"}
+	if !slices.Equal(got, want) {
+		t.Errorf("collectProxies() = %v, want %v", got, want)
+	}
+}
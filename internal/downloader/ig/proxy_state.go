@@ -0,0 +1,184 @@
+package ig
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	SnapshotInterval = 5 * time.Minute
+	// Snapshots older than this are ignored; free proxies rarely live long.
+	SnapshotMaxAge = 6 * time.Hour
+)
+
+type proxySnapshot struct {
+	SavedAt time.Time       `json:"saved_at"`
+	Proxies []snapshotEntry `json:"proxies"`
+}
+
+type snapshotEntry struct {
+	Addr      string        `json:"addr"`
+	Successes int           `json:"successes"`
+	Failures  int           `json:"failures"`
+	Latency   time.Duration `json:"latency"`
+}
+
+// saveState writes the healthy part of the pool to the state file so the
+// next start can serve requests before the first scan finishes.
+func (pm *ProxyManager) saveState() {
+	if pm.stateFile == "" {
+		return
+	}
+
+	snapshot := proxySnapshot{SavedAt: time.Now()}
+	pm.mu.Lock()
+	for _, p := range pm.proxies {
+		h := pm.healthLocked(p)
+		if h.quarantinedUntil.After(snapshot.SavedAt) {
+			continue
+		}
+		snapshot.Proxies = append(snapshot.Proxies, snapshotEntry{
+			Addr:      p,
+			Successes: h.successes,
+			Failures:  h.failures,
+			Latency:   h.latency,
+		})
+	}
+	pm.mu.Unlock()
+
+	// Keep the last good snapshot through an outage or a run of evictions.
+	if len(snapshot.Proxies) == 0 {
+		log.Debug().Msg("No healthy proxies, keeping previous snapshot")
+		return
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode proxy snapshot")
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(pm.stateFile), ".proxies-*")
+	if err != nil {
+		log.Error().Err(err).Str("file", pm.stateFile).Msg("Failed to write proxy snapshot")
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), pm.stateFile)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Error().Err(err).Str("file", pm.stateFile).Msg("Failed to write proxy snapshot")
+		return
+	}
+	log.Debug().Int("proxies", len(snapshot.Proxies)).Msg("Proxy snapshot saved")
+}
+
+// loadState seeds the pool from the state file and returns the loaded
+// addresses so they can be re-validated.
+func (pm *ProxyManager) loadState() []string {
+	if pm.stateFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(pm.stateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Str("file", pm.stateFile).Msg("Failed to read proxy snapshot")
+		}
+		return nil
+	}
+
+	var snapshot proxySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		log.Warn().Err(err).Str("file", pm.stateFile).Msg("Failed to decode proxy snapshot")
+		return nil
+	}
+	if age := time.Since(snapshot.SavedAt); age > SnapshotMaxAge {
+		log.Info().Dur("age", age).Msg("Proxy snapshot is too old, ignoring it")
+		return nil
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	var loaded []string
+	for _, entry := range snapshot.Proxies {
+		if entry.Addr == "" || slices.Contains(pm.proxies, entry.Addr) {
+			continue
+		}
+		pm.proxies = append(pm.proxies, entry.Addr)
+		pm.health[entry.Addr] = &proxyHealth{
+			successes: entry.Successes,
+			failures:  entry.Failures,
+			latency:   entry.Latency,
+		}
+		loaded = append(loaded, entry.Addr)
+	}
+	pm.signalReadyLocked()
+
+	log.Info().Int("proxies", len(loaded)).Msg("Loaded proxy snapshot")
+	return loaded
+}
+
+// revalidate re-checks proxies restored from a snapshot and drops the ones
+// that no longer work.
+func (pm *ProxyManager) revalidate(proxies []string) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 50)
+	var dropped int
+	var mu sync.Mutex
+
+	for _, p := range proxies {
+		wg.Add(1)
+		go func(proxyAddr string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if pm.checkProxy(proxyAddr) {
+				return
+			}
+			pm.mu.Lock()
+			pm.removeLocked(proxyAddr)
+			pm.mu.Unlock()
+
+			mu.Lock()
+			dropped++
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	log.Info().Int("checked", len(proxies)).Int("dropped", dropped).Msg("Re-validated proxy snapshot")
+}
+
+// withoutPooled drops candidates that are already in the pool, such as
+// snapshot entries that revalidate is checking.
+func (pm *ProxyManager) withoutPooled(candidates []string) []string {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pooled := make(map[string]struct{}, len(pm.proxies))
+	for _, p := range pm.proxies {
+		pooled[p] = struct{}{}
+	}
+
+	var fresh []string
+	for _, p := range candidates {
+		if _, ok := pooled[p]; !ok {
+			fresh = append(fresh, p)
+		}
+	}
+	return fresh
+}
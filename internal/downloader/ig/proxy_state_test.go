@@ -0,0 +1,70 @@
+package ig
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestManager(stateFile string) *ProxyManager {
+	return &ProxyManager{
+		health:    make(map[string]*proxyHealth),
+		ready:     make(chan struct{}),
+		stateFile: stateFile,
+	}
+}
+
+func TestProxySnapshotRoundTrip(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "proxies.json")
+
+	pm := newTestManager(stateFile)
+	pm.addOne("http://fast:80")
+	pm.addOne("http://bad:80")
+	pm.reportSuccess("http://fast:80", 200*time.Millisecond)
+	pm.reportFailure("http://bad:80", nil)
+	pm.saveState()
+
+	restored := newTestManager(stateFile)
+	loaded := restored.loadState()
+	if want := []string{"http://fast:80"}; !slices.Equal(loaded, want) {
+		t.Fatalf("loadState() = %v, want %v", loaded, want)
+	}
+	if h := restored.health["http://fast:80"]; h.successes != 1 || h.latency != 200*time.Millisecond {
+		t.Errorf("restored health = %+v", h)
+	}
+
+	fresh := restored.withoutPooled([]string{"http://fast:80", "http://new:80"})
+	if want := []string{"http://new:80"}; !slices.Equal(fresh, want) {
+		t.Errorf("withoutPooled() = %v, want %v", fresh, want)
+	}
+}
+
+func TestProxySnapshotKeptWhenPoolUnhealthy(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "proxies.json")
+
+	pm := newTestManager(stateFile)
+	pm.addOne("http://good:80")
+	pm.saveState()
+	before, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Quarantined and then evicted: neither state should reach the file.
+	pm.reportFailure("http://good:80", nil)
+	pm.saveState()
+	for n := 1; n < evictAfterFailures; n++ {
+		pm.reportFailure("http://good:80", nil)
+	}
+	pm.saveState()
+
+	after, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Errorf("snapshot was overwritten:\nbefore %s\nafter  %s", before, after)
+	}
+}
@@ -0,0 +1,157 @@
+package ig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/cache"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+)
+
+// flight is one in-progress download shared by every request for the same
+// key. It runs on its own context, which is cancelled only once all waiters
+// have given up, so one user cancelling doesn't abort it for the others.
+type flight struct {
+	done   chan struct{}
+	media  []cache.Item
+	err    error
+	cancel context.CancelFunc
+
+	waiters  int
+	nextID   int
+	progress map[int]downloader.ProgressFunc
+}
+
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+var postFlights = &flightGroup{flights: make(map[string]*flight)}
+
+func (g *flightGroup) Do(ctx context.Context, key string, progress downloader.ProgressFunc, fn func(context.Context, downloader.ProgressFunc) ([]cache.Item, error)) ([]*downloader.Media, error) {
+	g.mu.Lock()
+	f, ok := g.flights[key]
+	// A flight whose waiters all left has been cancelled; start a fresh one.
+	if !ok || f.waiters == 0 {
+		sharedCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{
+			done:     make(chan struct{}),
+			cancel:   cancel,
+			progress: make(map[int]downloader.ProgressFunc),
+		}
+		g.flights[key] = f
+		go g.run(sharedCtx, key, f, fn)
+	} else {
+		log.Info().Str("key", key).Int("waiters", f.waiters+1).Msg("Joining in-flight download")
+	}
+
+	id := f.nextID
+	f.nextID++
+	f.waiters++
+	if progress != nil {
+		f.progress[id] = progress
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		g.leave(f, id)
+		if f.err != nil {
+			return nil, f.err
+		}
+		return f.clone(), nil
+	case <-ctx.Done():
+		g.leave(f, id)
+		return nil, ctx.Err()
+	}
+}
+
+func (g *flightGroup) run(ctx context.Context, key string, f *flight, fn func(context.Context, downloader.ProgressFunc) ([]cache.Item, error)) {
+	defer f.cancel()
+
+	media, err := fn(ctx, f.report(g))
+
+	g.mu.Lock()
+	f.media = media
+	f.err = err
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+	g.mu.Unlock()
+
+	close(f.done)
+}
+
+func (g *flightGroup) leave(f *flight, id int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(f.progress, id)
+	f.waiters--
+	if f.waiters == 0 {
+		f.cancel()
+	}
+}
+
+// report fans progress out to every waiter currently attached to f.
+func (f *flight) report(g *flightGroup) downloader.ProgressFunc {
+	return func(p downloader.Progress) {
+		g.mu.Lock()
+		receivers := make([]downloader.ProgressFunc, 0, len(f.progress))
+		for _, fn := range f.progress {
+			receivers = append(receivers, fn)
+		}
+		g.mu.Unlock()
+
+		for _, fn := range receivers {
+			fn(p)
+		}
+	}
+}
+
+func (f *flight) clone() []*downloader.Media {
+	return toMedia(f.media)
+}
+
+func toMedia(items []cache.Item) []*downloader.Media {
+	media := make([]*downloader.Media, 0, len(items))
+	for _, item := range items {
+		media = append(media, &downloader.Media{
+			Name:        item.Name,
+			ContentType: item.ContentType,
+			Reader:      bytes.NewReader(item.Data),
+			Size:        int64(len(item.Data)),
+		})
+	}
+	return media
+}
+
+// readAll buffers every media reader so the result can be handed to each
+// waiter, closing readers that are HTTP bodies.
+func readAll(media []*downloader.Media) ([]cache.Item, error) {
+	stored := make([]cache.Item, 0, len(media))
+	var firstErr error
+	for _, m := range media {
+		data, err := io.ReadAll(m.Reader)
+		if closer, ok := m.Reader.(io.Closer); ok {
+			closer.Close()
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("Failed to read %s: %v", m.Name, err)
+		}
+		stored = append(stored, cache.Item{
+			Name:        m.Name,
+			ContentType: m.ContentType,
+			Data:        data,
+		})
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return stored, nil
+}
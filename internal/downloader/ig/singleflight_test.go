@@ -0,0 +1,175 @@
+package ig
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yokeTH/short-form-discord-app/internal/cache"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+)
+
+// blockingWork is a flight function that blocks until released or until its
+// shared context is cancelled.
+type blockingWork struct {
+	calls    atomic.Int32
+	started  chan struct{}
+	release  chan struct{}
+	canceled chan struct{}
+}
+
+func newBlockingWork() *blockingWork {
+	return &blockingWork{
+		started:  make(chan struct{}, 10),
+		release:  make(chan struct{}),
+		canceled: make(chan struct{}, 10),
+	}
+}
+
+func (w *blockingWork) fn(ctx context.Context, progress downloader.ProgressFunc) ([]cache.Item, error) {
+	w.calls.Add(1)
+	w.started <- struct{}{}
+	select {
+	case <-w.release:
+		progress(downloader.Progress{Stage: downloader.StageDownloadingVideo, Percent: 100})
+		return []cache.Item{{Name: "video.mp4", ContentType: "video/mp4", Data: []byte("data")}}, nil
+	case <-ctx.Done():
+		w.canceled <- struct{}{}
+		return nil, ctx.Err()
+	}
+}
+
+type result struct {
+	media []*downloader.Media
+	err   error
+}
+
+func doAsync(g *flightGroup, ctx context.Context, key string, progress downloader.ProgressFunc, fn func(context.Context, downloader.ProgressFunc) ([]cache.Item, error)) <-chan result {
+	ch := make(chan result, 1)
+	go func() {
+		media, err := g.Do(ctx, key, progress, fn)
+		ch <- result{media, err}
+	}()
+	return ch
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func waitResult(t *testing.T, ch <-chan result) result {
+	t.Helper()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for Do to return")
+		return result{}
+	}
+}
+
+func waiters(g *flightGroup, key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.flights[key]; ok {
+		return f.waiters
+	}
+	return 0
+}
+
+func waitWaiters(t *testing.T, g *flightGroup, key string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for waiters(g, key) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("waiters = %d, want %d", waiters(g, key), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFlightGroupJoinAndPartialCancel(t *testing.T) {
+	g := &flightGroup{flights: make(map[string]*flight)}
+	work := newBlockingWork()
+
+	var progressA, progressB atomic.Int32
+	ctxA, cancelA := context.WithCancel(context.Background())
+	defer cancelA()
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+
+	a := doAsync(g, ctxA, "key", func(downloader.Progress) { progressA.Add(1) }, work.fn)
+	waitFor(t, work.started, "flight to start")
+	b := doAsync(g, ctxB, "key", func(downloader.Progress) { progressB.Add(1) }, work.fn)
+	waitWaiters(t, g, "key", 2)
+
+	// One waiter leaving must not abort the shared download.
+	cancelA()
+	if r := waitResult(t, a); !errors.Is(r.err, context.Canceled) {
+		t.Fatalf("cancelled waiter err = %v, want context.Canceled", r.err)
+	}
+	waitWaiters(t, g, "key", 1)
+	select {
+	case <-work.canceled:
+		t.Fatal("shared work was cancelled while a waiter remained")
+	default:
+	}
+
+	close(work.release)
+	r := waitResult(t, b)
+	if r.err != nil {
+		t.Fatalf("remaining waiter err = %v", r.err)
+	}
+	if len(r.media) != 1 {
+		t.Fatalf("media = %d items, want 1", len(r.media))
+	}
+	data, _ := io.ReadAll(r.media[0].Reader)
+	if string(data) != "data" {
+		t.Errorf("media data = %q", data)
+	}
+
+	if n := work.calls.Load(); n != 1 {
+		t.Errorf("work ran %d times, want 1", n)
+	}
+	if progressB.Load() == 0 {
+		t.Error("remaining waiter got no progress")
+	}
+	if progressA.Load() != 0 {
+		t.Error("departed waiter still got progress")
+	}
+}
+
+func TestFlightGroupLastWaiterCancelsAndRestarts(t *testing.T) {
+	g := &flightGroup{flights: make(map[string]*flight)}
+	work := newBlockingWork()
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	a := doAsync(g, ctxA, "key", nil, work.fn)
+	waitFor(t, work.started, "first flight to start")
+
+	// The last waiter leaving cancels the shared context.
+	cancelA()
+	if r := waitResult(t, a); !errors.Is(r.err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", r.err)
+	}
+	waitFor(t, work.canceled, "shared work to be cancelled")
+
+	// A new caller must get a fresh flight rather than the cancelled one.
+	b := doAsync(g, context.Background(), "key", nil, work.fn)
+	waitFor(t, work.started, "second flight to start")
+	close(work.release)
+	if r := waitResult(t, b); r.err != nil || len(r.media) != 1 {
+		t.Fatalf("fresh flight = %v items, err %v", len(r.media), r.err)
+	}
+	if n := work.calls.Load(); n != 2 {
+		t.Errorf("work ran %d times, want 2", n)
+	}
+}
@@ -0,0 +1,178 @@
+package ig
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
+)
+
+const (
+	transcodeAudioBitrate = 96_000
+	minVideoBitrate       = 150_000
+	// Leave headroom for the MP4 container and encoder overshoot.
+	transcodeBudgetRatio = 0.92
+	maxTranscodeAttempts = 3
+)
+
+var durationPattern = regexp.MustCompile(`Duration: (\d+):(\d+):(\d+(?:\.\d+)?)`)
+
+// transcodeToFit downloads the source video and re-encodes it with a two-pass
+// x264 encode whose bitrate is derived from the duration and the size budget,
+// lowering the bitrate on each attempt until the output fits.
+func transcodeToFit(ctx context.Context, videoURL string, duration float64, opts downloader.Options) ([]byte, error) {
+	budget := opts.Limit()
+	tempDir, err := os.MkdirTemp("", "ig-transcode-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			log.Warn().Err(err).Str("dir", tempDir).Msg("Failed to clean up temp dir")
+		}
+	}()
+
+	sourcePath := filepath.Join(tempDir, "source.mp4")
+	outputPath := filepath.Join(tempDir, "encoded.mp4")
+	passLogPrefix := filepath.Join(tempDir, "ffmpeg2pass")
+
+	if err := downloadFile(ctx, videoURL, sourcePath, downloader.StageDownloadingVideo, opts); err != nil {
+		return nil, fmt.Errorf("failed to download source video: %w", err)
+	}
+
+	if duration <= 0 {
+		duration, err = probeDuration(ctx, sourcePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ratio := transcodeBudgetRatio
+	for attempt := 1; attempt <= maxTranscodeAttempts; attempt++ {
+		totalBitrate := int(float64(budget) * 8 * ratio / duration)
+		videoBitrate := totalBitrate - transcodeAudioBitrate
+		if videoBitrate < minVideoBitrate {
+			return nil, fmt.Errorf("video is too long to fit the upload limit (%.0fs)", duration)
+		}
+
+		height := targetHeight(videoBitrate)
+		log.Info().
+			Int("attempt", attempt).
+			Int("video_bitrate", videoBitrate).
+			Int("max_height", height).
+			Float64("duration", duration).
+			Msg("Re-encoding video to fit upload limit")
+
+		opts.Report(downloader.Progress{
+			Stage:   downloader.StageTranscoding,
+			Percent: -1,
+			Detail:  fmt.Sprintf("attempt %d/%d", attempt, maxTranscodeAttempts),
+		})
+		if err := encodeTwoPass(ctx, sourcePath, outputPath, passLogPrefix, videoBitrate, height); err != nil {
+			return nil, err
+		}
+
+		resultData, err := os.ReadFile(outputPath)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(resultData)) <= budget {
+			log.Info().Int("size", len(resultData)).Msg("Re-encoded video fits upload limit")
+			return resultData, nil
+		}
+
+		log.Warn().Int("size", len(resultData)).Int64("budget", budget).Msg("Re-encoded video too large, lowering bitrate")
+		ratio *= 0.85
+	}
+
+	return nil, fmt.Errorf("re-encoded video still exceeds the upload limit")
+}
+
+// targetHeight scales resolution down with the bitrate so low budgets don't
+// produce a blocky full-resolution encode.
+func targetHeight(videoBitrate int) int {
+	switch {
+	case videoBitrate >= 2_500_000:
+		return 1080
+	case videoBitrate >= 1_200_000:
+		return 720
+	case videoBitrate >= 600_000:
+		return 480
+	default:
+		return 360
+	}
+}
+
+func encodeTwoPass(ctx context.Context, sourcePath, outputPath, passLogPrefix string, videoBitrate, height int) error {
+	scale := fmt.Sprintf("scale=-2:'min(ih,%d)'", height)
+	bitrate := strconv.Itoa(videoBitrate)
+
+	firstPass := exec.CommandContext(ctx, "ffmpeg",
+		"-y",
+		"-i", sourcePath,
+		"-vf", scale,
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-b:v", bitrate,
+		"-pass", "1",
+		"-passlogfile", passLogPrefix,
+		"-an",
+		"-f", "mp4",
+		os.DevNull,
+	)
+	if output, err := firstPass.CombinedOutput(); err != nil {
+		log.Error().Str("ffmpeg_output", string(output)).Msg("FFmpeg first pass failed")
+		return fmt.Errorf("ffmpeg first pass failed: %w", err)
+	}
+
+	secondPass := exec.CommandContext(ctx, "ffmpeg",
+		"-y",
+		"-i", sourcePath,
+		"-vf", scale,
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-b:v", bitrate,
+		"-maxrate", bitrate,
+		"-bufsize", strconv.Itoa(videoBitrate*2),
+		"-pass", "2",
+		"-passlogfile", passLogPrefix,
+		"-c:a", "aac",
+		"-b:a", strconv.Itoa(transcodeAudioBitrate),
+		"-movflags", "+faststart",
+		outputPath,
+	)
+	if output, err := secondPass.CombinedOutput(); err != nil {
+		log.Error().Str("ffmpeg_output", string(output)).Msg("FFmpeg second pass failed")
+		return fmt.Errorf("ffmpeg second pass failed: %w", err)
+	}
+
+	return nil
+}
+
+// probeDuration reads the duration from ffmpeg's input banner, since the
+// runtime image ships ffmpeg without ffprobe.
+func probeDuration(ctx context.Context, path string) (float64, error) {
+	// ffmpeg exits non-zero without an output file, so only the banner matters.
+	output, _ := exec.CommandContext(ctx, "ffmpeg", "-i", path).CombinedOutput()
+
+	match := durationPattern.FindStringSubmatch(string(output))
+	if len(match) != 4 {
+		return 0, fmt.Errorf("unable to determine video duration")
+	}
+
+	hours, _ := strconv.ParseFloat(match[1], 64)
+	minutes, _ := strconv.ParseFloat(match[2], 64)
+	seconds, _ := strconv.ParseFloat(match[3], 64)
+
+	duration := hours*3600 + minutes*60 + seconds
+	if duration <= 0 {
+		return 0, fmt.Errorf("unable to determine video duration")
+	}
+	return duration, nil
+}
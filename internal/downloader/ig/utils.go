@@ -2,10 +2,14 @@ package ig
 
 import (
 	"fmt"
+	"io"
+	"mime"
 	"net/url"
 	"regexp"
+	"strings"
 
 	"github.com/rs/zerolog/log"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
 )
 
 func buildPostBody(shortcode string) string {
@@ -43,10 +47,17 @@ func buildPostBody(shortcode string) string {
 	}.Encode()
 }
 
+var postURLPattern = regexp.MustCompile(`https?://www\.instagram\.com/(?:p|reel|reels?)/([A-Za-z0-9_-]+)`)
+
+// FindURL returns the first Instagram post URL found in text.
+func FindURL(text string) (string, bool) {
+	match := postURLPattern.FindString(text)
+	return match, match != ""
+}
+
 func parseInstagramVideoURL(input string) (string, bool) {
 	log.Info().Str("input", input).Msg("Parsing Instagram video URL")
-	re := regexp.MustCompile(`https?://www\.instagram\.com/(?:p|reel|reels?)/([A-Za-z0-9_-]+)`)
-	match := re.FindStringSubmatch(input)
+	match := postURLPattern.FindStringSubmatch(input)
 	if len(match) > 1 {
 		log.Info().Str("postID", match[1]).Msg("Instagram video URL matched")
 		return match[1], true
@@ -54,3 +65,62 @@ func parseInstagramVideoURL(input string) (string, bool) {
 	log.Warn().Str("input", input).Msg("Instagram video URL did not match expected pattern")
 	return "", false
 }
+
+// progressReader reports read progress for a body of known length, emitting
+// at most one event per whole percent.
+type progressReader struct {
+	reader      io.Reader
+	total       int64
+	read        int64
+	lastPercent int
+	stage       downloader.Stage
+	opts        downloader.Options
+}
+
+func newProgressReader(r io.Reader, total int64, stage downloader.Stage, opts downloader.Options) io.Reader {
+	opts.Report(downloader.Progress{Stage: stage, Percent: -1})
+	if total <= 0 || opts.Progress == nil {
+		return r
+	}
+	return &progressReader{reader: r, total: total, lastPercent: -1, stage: stage, opts: opts}
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	p.read += int64(n)
+
+	percent := int(p.read * 100 / p.total)
+	if percent != p.lastPercent {
+		p.lastPercent = percent
+		p.opts.Report(downloader.Progress{Stage: p.stage, Percent: float64(percent)})
+	}
+	return n, err
+}
+
+// imageExtensions pins the extension for common image types, because
+// mime.ExtensionsByType sorts alphabetically and would give ".jfif" for JPEG.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/heic": ".heic",
+	"image/heif": ".heif",
+	"image/avif": ".avif",
+}
+
+// imageExtension returns the file extension matching contentType, falling
+// back to ".jpg" for unknown or non-image types.
+func imageExtension(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "image/") {
+		return ".jpg"
+	}
+	if ext, ok := imageExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".jpg"
+}
@@ -0,0 +1,24 @@
+package ig
+
+import "testing"
+
+func TestImageExtension(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{contentType: "image/jpeg", want: ".jpg"},
+		{contentType: "image/webp", want: ".webp"},
+		{contentType: "image/heic", want: ".heic"},
+		{contentType: "image/png; charset=binary", want: ".png"},
+		{contentType: "IMAGE/WEBP", want: ".webp"},
+		{contentType: "", want: ".jpg"},
+		{contentType: "application/octet-stream", want: ".jpg"},
+	}
+
+	for _, tt := range tests {
+		if got := imageExtension(tt.contentType); got != tt.want {
+			t.Errorf("imageExtension(%q) = %q, want %q", tt.contentType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package downloader
+
+type Stage string
+
+const (
+	StageQueued           Stage = "Queued"
+	StageFetchingMetadata Stage = "Fetching post"
+	StageDownloadingVideo Stage = "Downloading video"
+	StageDownloadingAudio Stage = "Downloading audio"
+	StageDownloadingImage Stage = "Downloading image"
+	StageMerging          Stage = "Merging video and audio"
+	StageTranscoding      Stage = "Re-encoding video"
+	StageUploading        Stage = "Uploading"
+)
+
+// Progress is a single pipeline event. Percent is in [0, 100], or negative
+// when the total size is unknown.
+type Progress struct {
+	Stage   Stage
+	Percent float64
+	Detail  string
+}
+
+type ProgressFunc func(Progress)
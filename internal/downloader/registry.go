@@ -0,0 +1,74 @@
+package downloader
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	mu          sync.RWMutex
+	downloaders []Downloader
+)
+
+func Register(d Downloader) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, existing := range downloaders {
+		if existing.Name() == d.Name() {
+			panic("downloader: Register called twice for " + d.Name())
+		}
+	}
+
+	downloaders = append(downloaders, d)
+	log.Info().Str("downloader", d.Name()).Msg("Downloader registered")
+}
+
+func Get(name string) (Downloader, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, d := range downloaders {
+		if d.Name() == name {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+func Lookup(url string) (Downloader, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, d := range downloaders {
+		if d.Match(url) {
+			return d, nil
+		}
+	}
+	return nil, ErrNoDownloader
+}
+
+func Downloaders() []Downloader {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	list := make([]Downloader, len(downloaders))
+	copy(list, downloaders)
+	return list
+}
+
+// FindURL returns the first URL in text that a registered downloader supports.
+func FindURL(text string) (string, Downloader, error) {
+	for _, field := range strings.Fields(text) {
+		candidate := strings.Trim(field, "<>()[]|*_~")
+		if !strings.HasPrefix(candidate, "http://") && !strings.HasPrefix(candidate, "https://") {
+			continue
+		}
+		if d, err := Lookup(candidate); err == nil {
+			return candidate, d, nil
+		}
+	}
+	return "", nil, ErrNoDownloader
+}
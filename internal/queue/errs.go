@@ -0,0 +1,10 @@
+package queue
+
+import "errors"
+
+var (
+	ErrFull      = errors.New("Job queue is full")
+	ErrClosed    = errors.New("Job queue is closed")
+	ErrCancelled = errors.New("Job cancelled")
+	ErrShutdown  = errors.New("Job aborted by shutdown")
+)
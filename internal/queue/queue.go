@@ -0,0 +1,225 @@
+package queue
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type Job func(ctx context.Context)
+
+// PositionFunc is told a waiting job's 1-based position in the queue each
+// time it changes.
+type PositionFunc func(position int)
+
+type job struct {
+	id         string
+	run        Job
+	onPosition PositionFunc
+
+	// cancel is set once the job starts; cause records a cancellation that
+	// arrived while the job was still waiting.
+	cancel context.CancelCauseFunc
+	cause  error
+}
+
+// Queue runs jobs on a fixed number of workers. Submit never blocks: once
+// the buffer is full new jobs are rejected with ErrFull so callers can tell
+// the user to retry instead of piling up goroutines and ffmpeg processes.
+type Queue struct {
+	jobs    chan *job
+	workers int
+	timeout time.Duration
+
+	mu      sync.Mutex
+	closed  bool
+	pending []*job
+	active  map[string]*job
+	wg      sync.WaitGroup
+}
+
+// New creates a queue. A timeout of zero or less means jobs have no
+// per-job deadline.
+func New(workers, size int, timeout time.Duration) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if size < 0 {
+		size = 0
+	}
+	return &Queue{
+		jobs:    make(chan *job, size),
+		workers: workers,
+		timeout: timeout,
+		active:  make(map[string]*job),
+	}
+}
+
+// Start launches the workers. Every job context derives from ctx and is
+// bounded by the per-job timeout, if any.
+func (q *Queue) Start(ctx context.Context) {
+	log.Info().Int("workers", q.workers).Int("size", cap(q.jobs)).Dur("timeout", q.timeout).Msg("Starting job queue")
+	for n := 0; n < q.workers; n++ {
+		q.wg.Add(1)
+		go q.worker(ctx, n)
+	}
+}
+
+func (q *Queue) Submit(id string, run Job, onPosition PositionFunc) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+
+	j := &job{id: id, run: run, onPosition: onPosition}
+	select {
+	case q.jobs <- j:
+		q.pending = append(q.pending, j)
+		q.active[id] = j
+		log.Info().Str("job", id).Int("pending", len(q.pending)).Msg("Job queued")
+		if onPosition != nil {
+			onPosition(len(q.pending))
+		}
+		return nil
+	default:
+		log.Warn().Str("job", id).Int("pending", len(q.pending)).Msg("Job queue is full, rejecting job")
+		return ErrFull
+	}
+}
+
+// Cancel aborts a waiting or running job. The job's context is cancelled
+// with ErrCancelled as its cause. It reports whether the job was found.
+func (q *Queue) Cancel(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j, ok := q.active[id]
+	if !ok {
+		return false
+	}
+
+	log.Info().Str("job", id).Msg("Cancelling job")
+	j.cancelWith(ErrCancelled)
+	return true
+}
+
+func (j *job) cancelWith(cause error) {
+	if j.cancel != nil {
+		j.cancel(cause)
+	} else if j.cause == nil {
+		j.cause = cause
+	}
+}
+
+// Drain stops accepting jobs and gives queued and running jobs up to grace
+// to finish. Whatever is left is then cancelled with ErrShutdown so each job
+// can tell its user to retry, and Drain waits up to abortWait for them to
+// return.
+func (q *Queue) Drain(grace, abortWait time.Duration) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.jobs)
+	remaining := len(q.active)
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	log.Info().Int("jobs", remaining).Dur("grace", grace).Msg("Draining job queue")
+	select {
+	case <-done:
+		log.Info().Msg("Job queue drained")
+		return
+	case <-time.After(grace):
+	}
+
+	q.mu.Lock()
+	log.Warn().Int("jobs", len(q.active)).Msg("Grace period elapsed, aborting remaining jobs")
+	for _, j := range q.active {
+		j.cancelWith(ErrShutdown)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(abortWait):
+		log.Error().Msg("Jobs did not stop after being aborted")
+	}
+}
+
+func (q *Queue) worker(ctx context.Context, n int) {
+	defer q.wg.Done()
+	for j := range q.jobs {
+		q.dequeue(j)
+		q.runJob(ctx, n, j)
+	}
+}
+
+// dequeue removes j from the pending list and tells the jobs still waiting
+// behind it about their new position.
+func (q *Queue) dequeue(j *job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for idx, pending := range q.pending {
+		if pending == j {
+			q.pending = append(q.pending[:idx], q.pending[idx+1:]...)
+			for pos, waiting := range q.pending[idx:] {
+				if waiting.onPosition != nil {
+					waiting.onPosition(idx + pos + 1)
+				}
+			}
+			return
+		}
+	}
+}
+
+func (q *Queue) runJob(ctx context.Context, n int, j *job) {
+	if q.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, q.timeout)
+		defer cancelTimeout()
+	}
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	q.mu.Lock()
+	j.cancel = cancel
+	if j.cause != nil {
+		cancel(j.cause)
+	}
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		delete(q.active, j.id)
+		q.mu.Unlock()
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().
+				Interface("panic", r).
+				Str("job", j.id).
+				Bytes("stack", debug.Stack()).
+				Msg("Recovered from panic in job")
+		}
+	}()
+
+	start := time.Now()
+	log.Info().Str("job", j.id).Int("worker", n).Msg("Job started")
+	j.run(jobCtx)
+	log.Info().Str("job", j.id).Int("worker", n).Dur("elapsed", time.Since(start)).Msg("Job finished")
+}
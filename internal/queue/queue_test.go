@@ -0,0 +1,48 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestJobTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		wantDeadline bool
+	}{
+		{name: "positive", timeout: time.Minute, wantDeadline: true},
+		{name: "zero", timeout: 0},
+		{name: "negative", timeout: -time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := New(1, 1, tt.timeout)
+			q.Start(context.Background())
+
+			type result struct {
+				err         error
+				hasDeadline bool
+			}
+			done := make(chan result, 1)
+			err := q.Submit("job", func(ctx context.Context) {
+				_, ok := ctx.Deadline()
+				done <- result{err: ctx.Err(), hasDeadline: ok}
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := <-done
+			if got.err != nil {
+				t.Errorf("job context already done: %v", got.err)
+			}
+			if got.hasDeadline != tt.wantDeadline {
+				t.Errorf("deadline = %v, want %v", got.hasDeadline, tt.wantDeadline)
+			}
+			q.Drain(time.Second, time.Second)
+		})
+	}
+}
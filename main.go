@@ -6,7 +6,9 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/rs/zerolog/log"
 	"github.com/yokeTH/short-form-discord-app/internal/bot"
+	"github.com/yokeTH/short-form-discord-app/internal/downloader"
 	"github.com/yokeTH/short-form-discord-app/internal/downloader/ig"
 )
 
@@ -14,9 +16,14 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
 
-	ig.InitializeProxies()
-
 	botCfg := bot.NewFromENV()
+
+	ig.InitializeProxies(botCfg.ProxyConfig(), botCfg.ProxySources()...)
+	downloader.Register(ig.New())
+
+	if err := ig.InitializeCache(botCfg.CacheDir, botCfg.CacheMaxSize, botCfg.CacheTTL); err != nil {
+		log.Error().Err(err).Msg("Unable to open media cache, continuing without it")
+	}
 	b := bot.New(botCfg)
 
 	b.Start(ctx, stop)